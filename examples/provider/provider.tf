@@ -14,8 +14,8 @@ provider "vaultoperator" {
     path       = "~/.kube/config"
     namespace  = "vault"
     service    = "vault"
-    localPort  = "8200"
-    remotePort = "8200"
+    # local_port defaults to 0, letting the OS pick a free port
+    remote_port = "8200"
     # optional exec:
     exec {
       api_version = "client.authentication.k8s.io/v1beta1"
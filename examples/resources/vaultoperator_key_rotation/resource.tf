@@ -0,0 +1,11 @@
+resource "time_rotating" "quarterly" {
+  rotation_days = 90
+}
+
+resource "vaultoperator_key_rotation" "example" {
+  interval = "720h"
+
+  triggers = {
+    rotation = time_rotating.quarterly.id
+  }
+}
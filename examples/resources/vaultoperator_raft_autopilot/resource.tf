@@ -0,0 +1,6 @@
+resource "vaultoperator_raft_autopilot" "example" {
+  cleanup_dead_servers               = true
+  dead_server_last_contact_threshold = "1h"
+  min_quorum                         = 3
+  server_stabilization_time          = "30s"
+}
@@ -0,0 +1,17 @@
+resource "vaultoperator_init" "example" {
+  secret_shares    = 5
+  secret_threshold = 3
+}
+
+resource "vaultoperator_raft_join" "example" {
+  for_each = toset(["vault-1", "vault-2"])
+
+  vault_connection {
+    pod_name = each.key
+  }
+
+  node_id         = each.key
+  leader_api_addr = "http://vault-0.vault-internal:8200"
+
+  depends_on = [vaultoperator_init.example]
+}
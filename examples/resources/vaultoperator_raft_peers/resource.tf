@@ -0,0 +1,5 @@
+resource "vaultoperator_raft_peers" "example" {
+  node_ids = ["vault-0", "vault-1", "vault-2"]
+
+  depends_on = [vaultoperator_raft_join.example]
+}
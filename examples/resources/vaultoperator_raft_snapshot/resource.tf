@@ -0,0 +1,25 @@
+variable "vault_version" {
+  type = string
+}
+
+resource "vaultoperator_raft_snapshot" "example" {
+  path = "${path.module}/vault-${var.vault_version}.snap"
+
+  triggers = {
+    vault_version = var.vault_version
+  }
+}
+
+resource "vaultoperator_raft_snapshot" "s3" {
+  s3 {
+    endpoint               = "https://minio.example.com:9000"
+    bucket                 = "backups"
+    key_prefix             = "vault/"
+    server_side_encryption = "AES256"
+    retain                 = 10
+  }
+
+  triggers = {
+    vault_version = var.vault_version
+  }
+}
@@ -0,0 +1,8 @@
+resource "vaultoperator_raft_snapshot_restore" "example" {
+  path   = vaultoperator_raft_snapshot.example.path
+  sha256 = vaultoperator_raft_snapshot.example.sha256
+
+  # The snapshot comes from the cluster initialized by vaultoperator_init
+  force       = true
+  unseal_keys = vaultoperator_init.example.keys
+}
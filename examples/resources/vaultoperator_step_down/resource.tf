@@ -0,0 +1,13 @@
+variable "node" {
+  default = "vault-0"
+}
+
+resource "vaultoperator_step_down" "example" {
+  vault_connection {
+    pod_name = var.node
+  }
+
+  triggers = {
+    node = var.node
+  }
+}
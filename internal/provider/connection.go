@@ -0,0 +1,190 @@
+package provider
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/hashicorp/terraform-plugin-sdk/v2/diag"
+	"github.com/hashicorp/terraform-plugin-sdk/v2/helper/schema"
+	"github.com/hashicorp/vault/api"
+)
+
+const (
+	argVaultConnection           = "vault_connection"
+	argVaultConnectionAddress    = "address"
+	argVaultConnectionSkipVerify = "skip_verify"
+	argVaultConnectionCACert     = "ca_cert"
+	argVaultConnectionToken      = "token"
+)
+
+// connectionSchema is the vault_connection block of every resource and data
+// source, which points them at another Vault than the provider's. It cannot
+// be named connection, which Terraform reserves for provisioners.
+func connectionSchema() *schema.Schema {
+	return &schema.Schema{
+		Description: "Talk to another Vault than the one configured on the provider, with the provider's other settings. Use it to target many Vault clusters from a single provider configuration. It is not named `connection`, which Terraform reserves for the provisioners' connection block.",
+		Type:        schema.TypeList,
+		Optional:    true,
+		MaxItems:    1,
+		Elem: &schema.Resource{
+			Schema: map[string]*schema.Schema{
+				argVaultConnectionAddress: {
+					Description: "Vault address, instead of the provider's `vault_addr` or `kube_config`. It is reached through the provider's `ssh_tunnel` or `proxy` when set.",
+					Type:        schema.TypeString,
+					Optional:    true,
+					ConflictsWith: []string{
+						fmt.Sprintf("%s.0.%s", argVaultConnection, argNameSpace),
+						fmt.Sprintf("%s.0.%s", argVaultConnection, argServiceName),
+						fmt.Sprintf("%s.0.%s", argVaultConnection, argPodName),
+					},
+				},
+				argVaultConnectionSkipVerify: {
+					Description: "Disable TLS certificate verification, in addition to the provider's `vault_skip_verify`.",
+					Type:        schema.TypeBool,
+					Optional:    true,
+				},
+				argVaultConnectionCACert: {
+					Description: "PEM-encoded CA certificate to verify Vault with.",
+					Type:        schema.TypeString,
+					Optional:    true,
+				},
+				argTLSServerName: {
+					Description: "Server name to verify the Vault certificate against.",
+					Type:        schema.TypeString,
+					Optional:    true,
+				},
+				argVaultConnectionToken: {
+					Description: "Vault token, instead of the provider's `vault_token`.",
+					Type:        schema.TypeString,
+					Optional:    true,
+					Sensitive:   true,
+				},
+				argNameSpace: {
+					Description: "Kubernetes namespace of Vault, instead of the one of the provider's `kube_config`. `ca_secret` is read from this namespace.",
+					Type:        schema.TypeString,
+					Optional:    true,
+				},
+				argServiceName: {
+					Description: "Kubernetes service name of Vault, instead of the one of the provider's `kube_config`.",
+					Type:        schema.TypeString,
+					Optional:    true,
+				},
+				argPodName: {
+					Description: "Name of the pod to forward to, instead of the one `pod_selection` of the provider's `kube_config` picks.",
+					Type:        schema.TypeString,
+					Optional:    true,
+				},
+			},
+		},
+	}
+}
+
+// connection returns the client to use for a resource or data source: the
+// provider's, or a client scoped to its vault_connection block.
+func (a *apiClient) connection(ctx context.Context, d *schema.ResourceData) (*apiClient, diag.Diagnostics) {
+	if diags := a.configure(ctx); diags.HasError() {
+		return nil, diags
+	}
+
+	conn, ok := d.Get(argVaultConnection).([]interface{})
+	if !ok || len(conn) == 0 || conn[0] == nil {
+		return a, nil
+	}
+	spec := conn[0].(map[string]interface{})
+
+	c := &apiClient{
+		url:       a.url,
+		kubeConn:  a.kubeConn,
+		tlsConfig: a.tlsConfig,
+		tunnel:    a.tunnel,
+		proxyURL:  a.proxyURL,
+		token:     a.token,
+	}
+
+	if token := spec[argVaultConnectionToken].(string); token != "" {
+		c.token = token
+	}
+
+	c.tlsConfig.Insecure = c.tlsConfig.Insecure || spec[argVaultConnectionSkipVerify].(bool)
+	if ca := spec[argVaultConnectionCACert].(string); ca != "" {
+		c.tlsConfig.CACertBytes = []byte(ca)
+	}
+	if serverName := spec[argTLSServerName].(string); serverName != "" {
+		c.tlsConfig.TLSServerName = serverName
+		c.kubeConn.tlsServerName = serverName
+	}
+
+	address := spec[argVaultConnectionAddress].(string)
+	namespace := spec[argNameSpace].(string)
+	service := spec[argServiceName].(string)
+	podName := spec[argPodName].(string)
+
+	switch {
+	case address != "":
+		c.url = address
+		c.kubeConn = kubeConn{}
+	case namespace != "" || service != "" || podName != "":
+		if a.kubeConn.kubeConfig == nil {
+			return nil, diag.Errorf("%q, %q and %q of %q require %q to be configured on the provider",
+				argNameSpace, argServiceName, argPodName, argVaultConnection, argKubeConfig)
+		}
+		if podName != "" {
+			if a.kubeConn.transport == transportServiceProxy {
+				return nil, diag.Errorf("%q of %q cannot be used when %q is %q",
+					argPodName, argVaultConnection, argTransport, transportServiceProxy)
+			}
+			c.kubeConn.podSelection = podSelectionName
+			c.kubeConn.podName = podName
+		}
+		if namespace != "" {
+			c.kubeConn.nameSpace = namespace
+		}
+		if service != "" {
+			c.kubeConn.serviceName = service
+		}
+
+		if err := c.configureKube(ctx); err != nil {
+			return nil, kubeDiagnostics(err)
+		}
+	}
+
+	logDebug("using connection to Vault at %s", c.url)
+
+	if err := c.configureVault(ctx); err != nil {
+		return nil, kubeDiagnostics(err)
+	}
+
+	return c, nil
+}
+
+// vault returns the Vault client to talk to, through a port forward to the
+// selected pod when Vault is reached through Kubernetes. The returned
+// function releases the port forward.
+func (a *apiClient) vault(ctx context.Context) (*api.Client, func(), diag.Diagnostics) {
+	if !a.kubeConn.portForwarding() {
+		return a.client, func() {}, nil
+	}
+
+	svc, pods, err := a.kubeConn.vaultPods(ctx)
+	if err != nil {
+		return nil, nil, kubeDiagnostics(err)
+	}
+
+	pod, err := a.kubeConn.selectPod(ctx, svc, pods)
+	if err != nil {
+		return nil, nil, kubeDiagnostics(err)
+	}
+
+	f, err := a.kubeConn.forward(ctx, svc, pod, a.kubeConn.localPort)
+	if err != nil {
+		return nil, nil, kubeDiagnostics(err)
+	}
+
+	c, err := a.kubeConn.vaultClient(a.client, f)
+	if err != nil {
+		f.close()
+		return nil, nil, diag.FromErr(err)
+	}
+
+	return c, f.close, nil
+}
@@ -0,0 +1,145 @@
+package provider
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/hashicorp/terraform-plugin-sdk/v2/helper/schema"
+	"github.com/hashicorp/terraform-plugin-sdk/v2/terraform"
+)
+
+// startInitStatusVault starts a fake Vault only answering sys/init.
+func startInitStatusVault(t *testing.T, initialized bool) *httptest.Server {
+	t.Helper()
+
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path != "/v1/sys/init" {
+			http.NotFound(w, r)
+			return
+		}
+		w.Header().Set("Content-Type", "application/json")
+		fmt.Fprintf(w, `{"initialized": %t}`, initialized)
+	}))
+	t.Cleanup(server.Close)
+
+	return server
+}
+
+func TestApiClient_connection(t *testing.T) {
+	ctx := context.TODO()
+	providerVault := startInitStatusVault(t, false)
+	connectionVault := startInitStatusVault(t, true)
+
+	p := New("dev")()
+	diags := configureNow(ctx, p, terraform.NewResourceConfigRaw(map[string]interface{}{
+		argVaultAddr: providerVault.URL,
+	}))
+	if diags.HasError() {
+		t.Fatal(diags)
+	}
+
+	cases := []struct {
+		name        string
+		raw         map[string]interface{}
+		id          string
+		initialized bool
+	}{
+		{
+			name: "provider",
+			raw:  map[string]interface{}{},
+			id:   providerVault.URL,
+		},
+		{
+			name: "connection",
+			raw: map[string]interface{}{
+				argVaultConnection: []interface{}{map[string]interface{}{
+					argVaultConnectionAddress: connectionVault.URL,
+				}},
+			},
+			id:          connectionVault.URL,
+			initialized: true,
+		},
+	}
+
+	for _, c := range cases {
+		t.Run(c.name, func(t *testing.T) {
+			d := schema.TestResourceDataRaw(t, providerDatasource().Schema, c.raw)
+
+			if diags := providerDatasourceRead(ctx, d, p.Meta()); diags.HasError() {
+				t.Fatal(diags)
+			}
+
+			if d.Id() != c.id {
+				t.Fatalf("expected id %s, got %s", c.id, d.Id())
+			}
+			if initialized := d.Get(argInitialized).(bool); initialized != c.initialized {
+				t.Fatalf("expected initialized %t, got %t", c.initialized, initialized)
+			}
+		})
+	}
+
+	t.Run("kube without kube_config", func(t *testing.T) {
+		d := schema.TestResourceDataRaw(t, providerDatasource().Schema, map[string]interface{}{
+			argVaultConnection: []interface{}{map[string]interface{}{
+				argNameSpace: "vault",
+			}},
+		})
+
+		if diags := providerDatasourceRead(ctx, d, p.Meta()); !diags.HasError() {
+			t.Fatal("expected an error without kube_config on the provider")
+		}
+	})
+}
+
+func TestApiClient_connection_kube(t *testing.T) {
+	ctx := context.TODO()
+
+	var paths []string
+	server, ca, _ := startKubeAPI(t, http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		paths = append(paths, r.URL.Path)
+		w.Header().Set("Content-Type", "application/json")
+		w.Write([]byte(`{"initialized": true}`))
+	}))
+
+	p := New("dev")()
+	diags := configureNow(ctx, p, terraform.NewResourceConfigRaw(map[string]interface{}{
+		argKubeConfig: []interface{}{map[string]interface{}{
+			argKubeHost:                 server.URL,
+			argKubeToken:                "proxy-token",
+			argKubeClusterCACertificate: ca,
+			argNameSpace:                "vault",
+			argServiceName:              "vault",
+			argTransport:                transportServiceProxy,
+		}},
+	}))
+	if diags.HasError() {
+		t.Fatal(diags)
+	}
+
+	d := schema.TestResourceDataRaw(t, providerDatasource().Schema, map[string]interface{}{
+		argVaultConnection: []interface{}{map[string]interface{}{
+			argNameSpace:   "vault-b",
+			argServiceName: "vault-b",
+		}},
+	})
+
+	if diags := providerDatasourceRead(ctx, d, p.Meta()); diags.HasError() {
+		t.Fatal(diags)
+	}
+
+	expected := "/api/v1/namespaces/vault-b/services/http:vault-b:8200/proxy/v1/sys/init"
+	if len(paths) != 1 || paths[0] != expected {
+		t.Fatalf("expected a request to %s, got %v", expected, paths)
+	}
+	if d.Id() != server.URL+"/api/v1/namespaces/vault-b/services/http:vault-b:8200/proxy" {
+		t.Fatalf("unexpected id %s", d.Id())
+	}
+
+	// The provider's own client is left untouched
+	if url := p.Meta().(*apiClient).url; url != server.URL+"/api/v1/namespaces/vault/services/http:vault:8200/proxy" {
+		t.Fatalf("unexpected provider url %s", url)
+	}
+}
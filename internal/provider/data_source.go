@@ -19,6 +19,7 @@ func providerDatasource() *schema.Resource {
 		ReadContext: providerDatasourceRead,
 
 		Schema: map[string]*schema.Schema{
+			argVaultConnection: connectionSchema(),
 			argInitialized: {
 				Description: "The current initialization state of Vault.",
 				Type:        schema.TypeBool,
@@ -28,12 +29,21 @@ func providerDatasource() *schema.Resource {
 	}
 }
 
-func providerDatasourceRead(_ context.Context, d *schema.ResourceData, meta interface{}) diag.Diagnostics {
-	client := meta.(*apiClient)
+func providerDatasourceRead(ctx context.Context, d *schema.ResourceData, meta interface{}) diag.Diagnostics {
+	client, diags := meta.(*apiClient).connection(ctx, d)
+	if diags.HasError() {
+		return diags
+	}
+
+	vaultClient, closeVault, diags := client.vault(ctx)
+	if diags.HasError() {
+		return diags
+	}
+	defer closeVault()
 
 	d.SetId(client.url)
 
-	res, err := client.client.Sys().InitStatus()
+	res, err := vaultClient.Sys().InitStatusWithContext(ctx)
 	if err != nil {
 		logError("failed to read init status from Vault: %v", err)
 		return diag.FromErr(err)
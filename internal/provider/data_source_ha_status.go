@@ -0,0 +1,132 @@
+package provider
+
+import (
+	"context"
+	"sort"
+	"time"
+
+	"github.com/hashicorp/terraform-plugin-sdk/v2/diag"
+	"github.com/hashicorp/terraform-plugin-sdk/v2/helper/schema"
+)
+
+const (
+	argNodes          = "nodes"
+	argHostname       = "hostname"
+	argAPIAddress     = "api_address"
+	argClusterAddress = "cluster_address"
+	argActiveNode     = "active_node"
+	argLastEcho       = "last_echo"
+	argUpgradeVersion = "upgrade_version"
+	argRedundancyZone = "redundancy_zone"
+)
+
+func dataSourceHAStatus() *schema.Resource {
+	return &schema.Resource{
+		// This description is used by the documentation generator and the language server.
+		Description: "Data source for the high availability status of the Vault cluster, listing every node. It requires a token.",
+
+		ReadContext: dataSourceHAStatusRead,
+
+		Schema: map[string]*schema.Schema{
+			argVaultConnection: connectionSchema(),
+			argNodes: {
+				Description: "The nodes of the cluster, ordered by API address.",
+				Type:        schema.TypeList,
+				Computed:    true,
+				Elem: &schema.Resource{
+					Schema: map[string]*schema.Schema{
+						argHostname: {
+							Description: "The hostname of the node.",
+							Type:        schema.TypeString,
+							Computed:    true,
+						},
+						argAPIAddress: {
+							Description: "The API address of the node.",
+							Type:        schema.TypeString,
+							Computed:    true,
+						},
+						argClusterAddress: {
+							Description: "The cluster address of the node.",
+							Type:        schema.TypeString,
+							Computed:    true,
+						},
+						argActiveNode: {
+							Description: "Whether the node is the active node.",
+							Type:        schema.TypeBool,
+							Computed:    true,
+						},
+						argLastEcho: {
+							Description: "When the standby last reached the active node, in RFC 3339 format. Empty for the active node.",
+							Type:        schema.TypeString,
+							Computed:    true,
+						},
+						argVersion: {
+							Description: "The Vault version of the node.",
+							Type:        schema.TypeString,
+							Computed:    true,
+						},
+						argUpgradeVersion: {
+							Description: "The Vault version the node upgrades to, while an autopilot upgrade is in progress. Vault Enterprise only.",
+							Type:        schema.TypeString,
+							Computed:    true,
+						},
+						argRedundancyZone: {
+							Description: "The redundancy zone of the node. Vault Enterprise only.",
+							Type:        schema.TypeString,
+							Computed:    true,
+						},
+					},
+				},
+			},
+		},
+	}
+}
+
+func dataSourceHAStatusRead(ctx context.Context, d *schema.ResourceData, meta interface{}) diag.Diagnostics {
+	client, diags := meta.(*apiClient).connection(ctx, d)
+	if diags.HasError() {
+		return diags
+	}
+
+	vaultClient, closeVault, diags := client.vault(ctx)
+	if diags.HasError() {
+		return diags
+	}
+	defer closeVault()
+
+	d.SetId(client.url)
+
+	status, err := vaultClient.Sys().HAStatusWithContext(ctx)
+	if err != nil {
+		logError("failed to read the HA status: %v", err)
+		return diag.FromErr(err)
+	}
+
+	sort.Slice(status.Nodes, func(i, j int) bool {
+		return status.Nodes[i].APIAddress < status.Nodes[j].APIAddress
+	})
+
+	nodes := make([]interface{}, len(status.Nodes))
+	for i, n := range status.Nodes {
+		lastEcho := ""
+		if n.LastEcho != nil {
+			lastEcho = n.LastEcho.Format(time.RFC3339)
+		}
+		nodes[i] = map[string]interface{}{
+			argHostname:       n.Hostname,
+			argAPIAddress:     n.APIAddress,
+			argClusterAddress: n.ClusterAddress,
+			argActiveNode:     n.ActiveNode,
+			argLastEcho:       lastEcho,
+			argVersion:        n.Version,
+			argUpgradeVersion: n.UpgradeVersion,
+			argRedundancyZone: n.RedundancyZone,
+		}
+	}
+
+	if err := d.Set(argNodes, nodes); err != nil {
+		return diag.FromErr(err)
+	}
+
+	return diag.Diagnostics{}
+}
@@ -0,0 +1,88 @@
+package provider
+
+import (
+	"context"
+	"fmt"
+	"testing"
+
+	"github.com/hashicorp/terraform-plugin-sdk/v2/helper/resource"
+	"github.com/hashicorp/terraform-plugin-sdk/v2/helper/schema"
+)
+
+var testAccDataSourceHAStatusVar = fmt.Sprintf("data.%[1]s.test", dsHAStatus)
+
+func testAccDataSourceHAStatus(addr, token string) string {
+	return fmt.Sprintf(`
+provider "%[1]s" {
+    vault_addr  = "%[3]s"
+    vault_token = "%[4]s"
+}
+
+data "%[2]s" "test" {
+}
+`, provider, dsHAStatus, addr, token)
+}
+
+func TestAccDataSourceHAStatus(t *testing.T) {
+	addr := startRaftVault(t, "node-1")
+	token := initRaftVault(t, addr)
+
+	resource.UnitTest(t, resource.TestCase{
+		PreCheck:          func() { testAccPreCheck(t) },
+		ProviderFactories: providerFactories,
+		Steps: []resource.TestStep{
+			{
+				Config: testAccDataSourceHAStatus(addr, token),
+				Check: resource.ComposeTestCheckFunc(
+					resource.TestCheckResourceAttr(testAccDataSourceHAStatusVar, "nodes.#", "1"),
+					resource.TestCheckResourceAttr(testAccDataSourceHAStatusVar, "nodes.0.api_address", addr),
+					resource.TestCheckResourceAttr(testAccDataSourceHAStatusVar, "nodes.0.active_node", "true"),
+					resource.TestCheckResourceAttrSet(testAccDataSourceHAStatusVar, "nodes.0.version"),
+				),
+			},
+		},
+	})
+}
+
+func TestDataSourceHAStatus(t *testing.T) {
+	ctx := context.TODO()
+
+	vault := newFakeVault(t)
+	vault.reply("sys/ha-status", func() interface{} {
+		return map[string]interface{}{
+			"nodes": []map[string]interface{}{
+				{
+					"hostname":    "vault-1",
+					"api_address": "https://node-2:8200",
+					"active_node": false,
+					"last_echo":   "2022-01-02T03:04:05Z",
+					"version":     "1.12.0",
+				},
+				{
+					"hostname":    "vault-0",
+					"api_address": "https://node-1:8200",
+					"active_node": true,
+					"version":     "1.12.0",
+				},
+			},
+		}
+	})
+
+	d := schema.TestResourceDataRaw(t, dataSourceHAStatus().Schema, map[string]interface{}{})
+	if diags := dataSourceHAStatusRead(ctx, d, vault.meta()); diags.HasError() {
+		t.Fatal(diags)
+	}
+
+	nodes := d.Get(argNodes).([]interface{})
+	if len(nodes) != 2 {
+		t.Fatalf("expected 2 nodes, got %d", len(nodes))
+	}
+	first := nodes[0].(map[string]interface{})
+	if first[argHostname] != "vault-0" || first[argActiveNode] != true || first[argLastEcho] != "" {
+		t.Errorf("expected the active node vault-0 first, got %v", first)
+	}
+	second := nodes[1].(map[string]interface{})
+	if second[argLastEcho] != "2022-01-02T03:04:05Z" {
+		t.Errorf("unexpected last echo %v", second[argLastEcho])
+	}
+}
@@ -0,0 +1,211 @@
+package provider
+
+import (
+	"context"
+	"strconv"
+	"time"
+
+	"github.com/hashicorp/terraform-plugin-sdk/v2/diag"
+	"github.com/hashicorp/terraform-plugin-sdk/v2/helper/schema"
+	"github.com/hashicorp/terraform-plugin-sdk/v2/helper/validation"
+	"github.com/hashicorp/vault/api"
+)
+
+const (
+	argStandbyOK                  = "standby_ok"
+	argPerfStandbyOK              = "perf_standby_ok"
+	argSealedCode                 = "sealed_code"
+	argUninitCode                 = "uninit_code"
+	argWaitFor                    = "wait_for"
+	argStatusCode                 = "status_code"
+	argStandby                    = "standby"
+	argReplicationPerformanceMode = "replication_performance_mode"
+	argReplicationDRMode          = "replication_dr_mode"
+	argServerTimeUTC              = "server_time_utc"
+	argClusterName                = "cluster_name"
+	argClusterID                  = "cluster_id"
+
+	waitForInitialized = "initialized"
+	waitForUnsealed    = "unsealed"
+	waitForActive      = "active"
+	waitForHealthy     = "healthy"
+)
+
+func dataSourceHealth() *schema.Resource {
+	return &schema.Resource{
+		// This description is used by the documentation generator and the language server.
+		Description: "Data source for the health of the Vault node connected to. With `wait_for`, reading it blocks until the node reaches the given state, or the read timeout.",
+
+		ReadContext: dataSourceHealthRead,
+		Timeouts: &schema.ResourceTimeout{
+			Read: schema.DefaultTimeout(5 * time.Minute),
+		},
+
+		Schema: map[string]*schema.Schema{
+			argVaultConnection: connectionSchema(),
+			argStandbyOK: {
+				Description: "Whether a standby node is healthy, with the status code of an active node.",
+				Type:        schema.TypeBool,
+				Optional:    true,
+				Default:     false,
+			},
+			argPerfStandbyOK: {
+				Description: "Whether a performance standby node is healthy, with the status code of an active node.",
+				Type:        schema.TypeBool,
+				Optional:    true,
+				Default:     false,
+			},
+			argSealedCode: {
+				Description:  "The status code of a sealed node.",
+				Type:         schema.TypeInt,
+				Optional:     true,
+				Default:      503,
+				ValidateFunc: validation.IntBetween(200, 599),
+			},
+			argUninitCode: {
+				Description:  "The status code of an uninitialized node.",
+				Type:         schema.TypeInt,
+				Optional:     true,
+				Default:      501,
+				ValidateFunc: validation.IntBetween(200, 599),
+			},
+			argWaitFor: {
+				Description:  "The state to wait for: `initialized`, `unsealed`, `active`, or `healthy` for a 2xx status code.",
+				Type:         schema.TypeString,
+				Optional:     true,
+				ValidateFunc: validation.StringInSlice([]string{waitForInitialized, waitForUnsealed, waitForActive, waitForHealthy}, false),
+			},
+			argStatusCode: {
+				Description: "The status code of the health check, following the codes configured.",
+				Type:        schema.TypeInt,
+				Computed:    true,
+			},
+			argInitialized: {
+				Description: "Whether Vault is initialized.",
+				Type:        schema.TypeBool,
+				Computed:    true,
+			},
+			argSealed: {
+				Description: "Whether the node is sealed.",
+				Type:        schema.TypeBool,
+				Computed:    true,
+			},
+			argStandby: {
+				Description: "Whether the node is a standby.",
+				Type:        schema.TypeBool,
+				Computed:    true,
+			},
+			argPerformanceStandby: {
+				Description: "Whether the node is a performance standby. Vault Enterprise only.",
+				Type:        schema.TypeBool,
+				Computed:    true,
+			},
+			argReplicationPerformanceMode: {
+				Description: "The performance replication mode, like `disabled`.",
+				Type:        schema.TypeString,
+				Computed:    true,
+			},
+			argReplicationDRMode: {
+				Description: "The disaster recovery replication mode, like `disabled`.",
+				Type:        schema.TypeString,
+				Computed:    true,
+			},
+			argServerTimeUTC: {
+				Description: "The time of the node, in seconds since the epoch.",
+				Type:        schema.TypeInt,
+				Computed:    true,
+			},
+			argVersion: {
+				Description: "The Vault version of the node.",
+				Type:        schema.TypeString,
+				Computed:    true,
+			},
+			argClusterName: {
+				Description: "The name of the cluster. Empty while the node is sealed.",
+				Type:        schema.TypeString,
+				Computed:    true,
+			},
+			argClusterID: {
+				Description: "The ID of the cluster. Empty while the node is sealed.",
+				Type:        schema.TypeString,
+				Computed:    true,
+			},
+		},
+	}
+}
+
+func dataSourceHealthRead(ctx context.Context, d *schema.ResourceData, meta interface{}) diag.Diagnostics {
+	client, diags := meta.(*apiClient).connection(ctx, d)
+	if diags.HasError() {
+		return diags
+	}
+
+	vaultClient, closeVault, diags := client.vault(ctx)
+	if diags.HasError() {
+		return diags
+	}
+	defer closeVault()
+
+	d.SetId(client.url)
+
+	params := map[string][]string{
+		"standbyok":     {strconv.FormatBool(d.Get(argStandbyOK).(bool))},
+		"perfstandbyok": {strconv.FormatBool(d.Get(argPerfStandbyOK).(bool))},
+		"sealedcode":    {strconv.Itoa(d.Get(argSealedCode).(int))},
+		"uninitcode":    {strconv.Itoa(d.Get(argUninitCode).(int))},
+	}
+	waitFor := d.Get(argWaitFor).(string)
+
+	var health *api.HealthResponse
+	var code int
+	var err error
+	if waitFor == "" {
+		health, code, err = readHealth(ctx, vaultClient, params)
+	} else {
+		reached := func(health *api.HealthResponse, code int) bool {
+			return healthReached(waitFor, health, code)
+		}
+		health, code, err = waitHealth(ctx, vaultClient, params, waitFor, reached, time.Now().Add(d.Timeout(schema.TimeoutRead)))
+	}
+	if err != nil {
+		logError("failed to read the health: %v", err)
+		return diag.FromErr(err)
+	}
+
+	values := map[string]interface{}{
+		argStatusCode:                 code,
+		argInitialized:                health.Initialized,
+		argSealed:                     health.Sealed,
+		argStandby:                    health.Standby,
+		argPerformanceStandby:         health.PerformanceStandby,
+		argReplicationPerformanceMode: health.ReplicationPerformanceMode,
+		argReplicationDRMode:          health.ReplicationDRMode,
+		argServerTimeUTC:              int(health.ServerTimeUTC),
+		argVersion:                    health.Version,
+		argClusterName:                health.ClusterName,
+		argClusterID:                  health.ClusterID,
+	}
+	for k, v := range values {
+		if err := d.Set(k, v); err != nil {
+			return diag.FromErr(err)
+		}
+	}
+
+	return diag.Diagnostics{}
+}
+
+// healthReached tells whether the node is in the state waitFor.
+func healthReached(waitFor string, health *api.HealthResponse, code int) bool {
+	switch waitFor {
+	case waitForInitialized:
+		return health.Initialized
+	case waitForUnsealed:
+		return health.Initialized && !health.Sealed
+	case waitForActive:
+		return health.Initialized && !health.Sealed && !health.Standby
+	case waitForHealthy:
+		return code >= 200 && code < 300
+	default:
+		return false
+	}
+}
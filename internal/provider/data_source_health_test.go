@@ -0,0 +1,161 @@
+package provider
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"testing"
+	"time"
+
+	"github.com/hashicorp/terraform-plugin-sdk/v2/helper/resource"
+	"github.com/hashicorp/terraform-plugin-sdk/v2/helper/schema"
+)
+
+var testAccDataSourceHealthVar = fmt.Sprintf("data.%[1]s.test", dsHealth)
+
+func testAccDataSourceHealth(addr, token string) string {
+	return fmt.Sprintf(`
+provider "%[1]s" {
+    vault_addr  = "%[3]s"
+    vault_token = "%[4]s"
+}
+
+data "%[2]s" "test" {
+	wait_for = "active"
+}
+`, provider, dsHealth, addr, token)
+}
+
+func TestAccDataSourceHealth(t *testing.T) {
+	addr := startRaftVault(t, "node-1")
+	token := initRaftVault(t, addr)
+
+	resource.UnitTest(t, resource.TestCase{
+		PreCheck:          func() { testAccPreCheck(t) },
+		ProviderFactories: providerFactories,
+		Steps: []resource.TestStep{
+			{
+				Config: testAccDataSourceHealth(addr, token),
+				Check: resource.ComposeTestCheckFunc(
+					resource.TestCheckResourceAttr(testAccDataSourceHealthVar, argStatusCode, "200"),
+					resource.TestCheckResourceAttr(testAccDataSourceHealthVar, argInitialized, "true"),
+					resource.TestCheckResourceAttr(testAccDataSourceHealthVar, argSealed, "false"),
+					resource.TestCheckResourceAttr(testAccDataSourceHealthVar, argStandby, "false"),
+					resource.TestCheckResourceAttrSet(testAccDataSourceHealthVar, argVersion),
+					resource.TestCheckResourceAttrSet(testAccDataSourceHealthVar, argClusterID),
+				),
+			},
+		},
+	})
+}
+
+// handleHealth registers sys/health on vault, sealed for the first
+// sealedReads health checks, and answering with the codes it is given like
+// Vault. It returns the number of health checks.
+func handleHealth(vault *fakeVault, sealedReads int) *int {
+	reads := 0
+	vault.handle("sys/health", func(w http.ResponseWriter, r *http.Request) {
+		reads++
+		sealed := reads <= sealedReads
+
+		code := http.StatusOK
+		if sealed {
+			code = http.StatusServiceUnavailable
+			if c := r.URL.Query().Get("sealedcode"); c != "" {
+				fmt.Sscan(c, &code)
+			}
+		}
+
+		w.Header().Set("Content-Type", "application/json")
+		w.WriteHeader(code)
+		json.NewEncoder(w).Encode(map[string]interface{}{
+			"initialized":                  true,
+			"sealed":                       sealed,
+			"standby":                      false,
+			"replication_performance_mode": "disabled",
+			"replication_dr_mode":          "disabled",
+			"server_time_utc":              1641092645,
+			"version":                      "1.12.0",
+		})
+	})
+
+	return &reads
+}
+
+func TestDataSourceHealth(t *testing.T) {
+	ctx := context.TODO()
+
+	for _, tc := range []struct {
+		name        string
+		config      map[string]interface{}
+		sealedReads int
+		reads       int
+		code        int
+		sealed      bool
+	}{
+		{
+			name:        "sealed",
+			config:      map[string]interface{}{},
+			sealedReads: 1,
+			reads:       1,
+			code:        http.StatusServiceUnavailable,
+			sealed:      true,
+		},
+		{
+			name:        "sealed code",
+			config:      map[string]interface{}{argSealedCode: 200},
+			sealedReads: 1,
+			reads:       1,
+			code:        http.StatusOK,
+			sealed:      true,
+		},
+		{
+			name:        "wait for unsealed",
+			config:      map[string]interface{}{argWaitFor: waitForUnsealed},
+			sealedReads: 1,
+			reads:       2,
+			code:        http.StatusOK,
+			sealed:      false,
+		},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			vault := newFakeVault(t)
+			reads := handleHealth(vault, tc.sealedReads)
+			meta := vault.meta()
+
+			d := schema.TestResourceDataRaw(t, dataSourceHealth().Schema, tc.config)
+			if diags := dataSourceHealthRead(ctx, d, meta); diags.HasError() {
+				t.Fatal(diags)
+			}
+
+			if *reads != tc.reads {
+				t.Errorf("expected %d reads, got %d", tc.reads, *reads)
+			}
+			if code := d.Get(argStatusCode).(int); code != tc.code {
+				t.Errorf("expected status code %d, got %d", tc.code, code)
+			}
+			if sealed := d.Get(argSealed).(bool); sealed != tc.sealed {
+				t.Errorf("expected sealed %t, got %t", tc.sealed, sealed)
+			}
+			if mode := d.Get(argReplicationDRMode).(string); mode != "disabled" {
+				t.Errorf("expected the DR replication to be disabled, got %s", mode)
+			}
+		})
+	}
+}
+
+func TestDataSourceHealthTimeout(t *testing.T) {
+	ctx, cancel := context.WithTimeout(context.TODO(), 100*time.Millisecond)
+	defer cancel()
+	vault := newFakeVault(t)
+	handleHealth(vault, 1000)
+	meta := vault.meta()
+
+	d := schema.TestResourceDataRaw(t, dataSourceHealth().Schema, map[string]interface{}{
+		argWaitFor: waitForHealthy,
+	})
+	if diags := dataSourceHealthRead(ctx, d, meta); !diags.HasError() {
+		t.Error("expected the wait to be cancelled")
+	}
+}
@@ -0,0 +1,70 @@
+package provider
+
+import (
+	"context"
+	"time"
+
+	"github.com/hashicorp/terraform-plugin-sdk/v2/diag"
+	"github.com/hashicorp/terraform-plugin-sdk/v2/helper/schema"
+)
+
+func dataSourceKeyStatus() *schema.Resource {
+	return &schema.Resource{
+		// This description is used by the documentation generator and the language server.
+		Description: "Data source for vault operator key-status. It requires a token.",
+
+		ReadContext: dataSourceKeyStatusRead,
+
+		Schema: map[string]*schema.Schema{
+			argVaultConnection: connectionSchema(),
+			argTerm: {
+				Description: "The term of the current key, incremented by every rotation.",
+				Type:        schema.TypeInt,
+				Computed:    true,
+			},
+			argInstallTime: {
+				Description: "When the current key was installed, in RFC 3339 format.",
+				Type:        schema.TypeString,
+				Computed:    true,
+			},
+			argEncryptions: {
+				Description: "The number of encryptions done with the current key.",
+				Type:        schema.TypeInt,
+				Computed:    true,
+			},
+		},
+	}
+}
+
+func dataSourceKeyStatusRead(ctx context.Context, d *schema.ResourceData, meta interface{}) diag.Diagnostics {
+	client, diags := meta.(*apiClient).connection(ctx, d)
+	if diags.HasError() {
+		return diags
+	}
+
+	vaultClient, closeVault, diags := client.vault(ctx)
+	if diags.HasError() {
+		return diags
+	}
+	defer closeVault()
+
+	d.SetId(client.url)
+
+	status, err := vaultClient.Sys().KeyStatusWithContext(ctx)
+	if err != nil {
+		logError("failed to read the key status: %v", err)
+		return diag.FromErr(err)
+	}
+
+	if err := d.Set(argTerm, status.Term); err != nil {
+		return diag.FromErr(err)
+	}
+	if err := d.Set(argInstallTime, status.InstallTime.Format(time.RFC3339)); err != nil {
+		return diag.FromErr(err)
+	}
+	if err := d.Set(argEncryptions, status.Encryptions); err != nil {
+		return diag.FromErr(err)
+	}
+
+	return diag.Diagnostics{}
+}
@@ -0,0 +1,116 @@
+package provider
+
+import (
+	"context"
+	"time"
+
+	"github.com/hashicorp/terraform-plugin-sdk/v2/diag"
+	"github.com/hashicorp/terraform-plugin-sdk/v2/helper/schema"
+)
+
+const (
+	argHAEnabled            = "ha_enabled"
+	argIsSelf               = "is_self"
+	argActiveTime           = "active_time"
+	argLeaderClusterAddress = "leader_cluster_address"
+	argPerformanceStandby   = "performance_standby"
+	argRaftCommittedIndex   = "raft_committed_index"
+	argRaftAppliedIndex     = "raft_applied_index"
+)
+
+func dataSourceLeader() *schema.Resource {
+	return &schema.Resource{
+		// This description is used by the documentation generator and the language server.
+		Description: "Data source for the leader of the Vault cluster, as seen by the node connected to.",
+
+		ReadContext: dataSourceLeaderRead,
+
+		Schema: map[string]*schema.Schema{
+			argVaultConnection: connectionSchema(),
+			argHAEnabled: {
+				Description: "Whether Vault runs in high availability mode.",
+				Type:        schema.TypeBool,
+				Computed:    true,
+			},
+			argIsSelf: {
+				Description: "Whether the node connected to is the active node.",
+				Type:        schema.TypeBool,
+				Computed:    true,
+			},
+			argActiveTime: {
+				Description: "Since when the active node is active, in RFC 3339 format.",
+				Type:        schema.TypeString,
+				Computed:    true,
+			},
+			argLeaderAddress: {
+				Description: "The API address of the active node.",
+				Type:        schema.TypeString,
+				Computed:    true,
+			},
+			argLeaderClusterAddress: {
+				Description: "The cluster address of the active node.",
+				Type:        schema.TypeString,
+				Computed:    true,
+			},
+			argPerformanceStandby: {
+				Description: "Whether the node connected to is a performance standby. Vault Enterprise only.",
+				Type:        schema.TypeBool,
+				Computed:    true,
+			},
+			argRaftCommittedIndex: {
+				Description: "The raft index committed by the node connected to. Raft storage only.",
+				Type:        schema.TypeInt,
+				Computed:    true,
+			},
+			argRaftAppliedIndex: {
+				Description: "The raft index applied by the node connected to. Raft storage only.",
+				Type:        schema.TypeInt,
+				Computed:    true,
+			},
+		},
+	}
+}
+
+func dataSourceLeaderRead(ctx context.Context, d *schema.ResourceData, meta interface{}) diag.Diagnostics {
+	client, diags := meta.(*apiClient).connection(ctx, d)
+	if diags.HasError() {
+		return diags
+	}
+
+	vaultClient, closeVault, diags := client.vault(ctx)
+	if diags.HasError() {
+		return diags
+	}
+	defer closeVault()
+
+	d.SetId(client.url)
+
+	leader, err := vaultClient.Sys().LeaderWithContext(ctx)
+	if err != nil {
+		logError("failed to read the leader: %v", err)
+		return diag.FromErr(err)
+	}
+
+	activeTime := ""
+	if !leader.ActiveTime.IsZero() {
+		activeTime = leader.ActiveTime.Format(time.RFC3339)
+	}
+
+	values := map[string]interface{}{
+		argHAEnabled:            leader.HAEnabled,
+		argIsSelf:               leader.IsSelf,
+		argActiveTime:           activeTime,
+		argLeaderAddress:        leader.LeaderAddress,
+		argLeaderClusterAddress: leader.LeaderClusterAddress,
+		argPerformanceStandby:   leader.PerfStandby,
+		argRaftCommittedIndex:   int(leader.RaftCommittedIndex),
+		argRaftAppliedIndex:     int(leader.RaftAppliedIndex),
+	}
+	for k, v := range values {
+		if err := d.Set(k, v); err != nil {
+			return diag.FromErr(err)
+		}
+	}
+
+	return diag.Diagnostics{}
+}
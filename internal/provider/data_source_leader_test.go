@@ -0,0 +1,81 @@
+package provider
+
+import (
+	"context"
+	"fmt"
+	"testing"
+
+	"github.com/hashicorp/terraform-plugin-sdk/v2/helper/resource"
+	"github.com/hashicorp/terraform-plugin-sdk/v2/helper/schema"
+)
+
+var testAccDataSourceLeaderVar = fmt.Sprintf("data.%[1]s.test", dsLeader)
+
+func testAccDataSourceLeader(addr, token string) string {
+	return fmt.Sprintf(`
+provider "%[1]s" {
+    vault_addr  = "%[3]s"
+    vault_token = "%[4]s"
+}
+
+data "%[2]s" "test" {
+}
+`, provider, dsLeader, addr, token)
+}
+
+func TestAccDataSourceLeader(t *testing.T) {
+	addr := startRaftVault(t, "node-1")
+	token := initRaftVault(t, addr)
+
+	resource.UnitTest(t, resource.TestCase{
+		PreCheck:          func() { testAccPreCheck(t) },
+		ProviderFactories: providerFactories,
+		Steps: []resource.TestStep{
+			{
+				Config: testAccDataSourceLeader(addr, token),
+				Check: resource.ComposeTestCheckFunc(
+					resource.TestCheckResourceAttr(testAccDataSourceLeaderVar, argHAEnabled, "true"),
+					resource.TestCheckResourceAttr(testAccDataSourceLeaderVar, argIsSelf, "true"),
+					resource.TestCheckResourceAttr(testAccDataSourceLeaderVar, argLeaderAddress, addr),
+					resource.TestCheckResourceAttrSet(testAccDataSourceLeaderVar, argActiveTime),
+					resource.TestCheckResourceAttrSet(testAccDataSourceLeaderVar, argRaftAppliedIndex),
+				),
+			},
+		},
+	})
+}
+
+func TestDataSourceLeader(t *testing.T) {
+	ctx := context.TODO()
+
+	vault := newFakeVault(t)
+	vault.reply("sys/leader", func() interface{} {
+		return map[string]interface{}{
+			"ha_enabled":             true,
+			"is_self":                false,
+			"active_time":            "2022-01-02T03:04:05Z",
+			"leader_address":         "https://node-2:8200",
+			"leader_cluster_address": "https://node-2:8201",
+			"raft_committed_index":   42,
+			"raft_applied_index":     41,
+		}
+	})
+
+	d := schema.TestResourceDataRaw(t, dataSourceLeader().Schema, map[string]interface{}{})
+	if diags := dataSourceLeaderRead(ctx, d, vault.meta()); diags.HasError() {
+		t.Fatal(diags)
+	}
+
+	if !d.Get(argHAEnabled).(bool) || d.Get(argIsSelf).(bool) {
+		t.Errorf("expected a standby of a HA cluster")
+	}
+	if activeTime := d.Get(argActiveTime).(string); activeTime != "2022-01-02T03:04:05Z" {
+		t.Errorf("unexpected active time %s", activeTime)
+	}
+	if address := d.Get(argLeaderClusterAddress).(string); address != "https://node-2:8201" {
+		t.Errorf("unexpected leader cluster address %s", address)
+	}
+	if index := d.Get(argRaftAppliedIndex).(int); index != 41 {
+		t.Errorf("expected applied index 41, got %d", index)
+	}
+}
@@ -0,0 +1,206 @@
+package provider
+
+import (
+	"context"
+	"sort"
+
+	"github.com/hashicorp/terraform-plugin-sdk/v2/diag"
+	"github.com/hashicorp/terraform-plugin-sdk/v2/helper/schema"
+)
+
+const (
+	argHealthy                    = "healthy"
+	argFailureTolerance           = "failure_tolerance"
+	argOptimisticFailureTolerance = "optimistic_failure_tolerance"
+	argVoters                     = "voters"
+	argNonVoters                  = "non_voters"
+	argID                         = "id"
+	argNodeStatus                 = "node_status"
+	argLastContact                = "last_contact"
+	argLastTerm                   = "last_term"
+	argLastIndex                  = "last_index"
+	argStableSince                = "stable_since"
+	argStatus                     = "status"
+	argVersion                    = "version"
+	argNodeType                   = "node_type"
+)
+
+func dataSourceRaftAutopilotState() *schema.Resource {
+	return &schema.Resource{
+		// This description is used by the documentation generator and the language server.
+		Description: "Data source for vault operator raft autopilot state. It requires a token.",
+
+		ReadContext: dataSourceRaftAutopilotStateRead,
+
+		Schema: map[string]*schema.Schema{
+			argVaultConnection: connectionSchema(),
+			argHealthy: {
+				Description: "Whether every server of the cluster is healthy.",
+				Type:        schema.TypeBool,
+				Computed:    true,
+			},
+			argFailureTolerance: {
+				Description: "How many voters can fail without the cluster losing quorum.",
+				Type:        schema.TypeInt,
+				Computed:    true,
+			},
+			argOptimisticFailureTolerance: {
+				Description: "How many voters can fail without the cluster losing quorum, counting the non-voters which would be promoted. Vault Enterprise only.",
+				Type:        schema.TypeInt,
+				Computed:    true,
+			},
+			argLeader: {
+				Description: "The node ID of the leader.",
+				Type:        schema.TypeString,
+				Computed:    true,
+			},
+			argVoters: {
+				Description: "The node IDs of the voters.",
+				Type:        schema.TypeList,
+				Computed:    true,
+				Elem:        &schema.Schema{Type: schema.TypeString},
+			},
+			argNonVoters: {
+				Description: "The node IDs of the non-voters.",
+				Type:        schema.TypeList,
+				Computed:    true,
+				Elem:        &schema.Schema{Type: schema.TypeString},
+			},
+			argServers: {
+				Description: "The servers of the cluster, ordered by node ID.",
+				Type:        schema.TypeList,
+				Computed:    true,
+				Elem: &schema.Resource{
+					Schema: map[string]*schema.Schema{
+						argID: {
+							Description: "The raft node ID of the server.",
+							Type:        schema.TypeString,
+							Computed:    true,
+						},
+						argName: {
+							Description: "The name of the server.",
+							Type:        schema.TypeString,
+							Computed:    true,
+						},
+						argAddress: {
+							Description: "The cluster address of the server.",
+							Type:        schema.TypeString,
+							Computed:    true,
+						},
+						argNodeStatus: {
+							Description: "The status of the server, like `alive`.",
+							Type:        schema.TypeString,
+							Computed:    true,
+						},
+						argStatus: {
+							Description: "The raft role of the server, like `leader`, `voter` or `non-voter`.",
+							Type:        schema.TypeString,
+							Computed:    true,
+						},
+						argHealthy: {
+							Description: "Whether the server is healthy.",
+							Type:        schema.TypeBool,
+							Computed:    true,
+						},
+						argLastContact: {
+							Description: "How long ago the server last contacted the leader.",
+							Type:        schema.TypeString,
+							Computed:    true,
+						},
+						argLastTerm: {
+							Description: "The last raft term of the server.",
+							Type:        schema.TypeInt,
+							Computed:    true,
+						},
+						argLastIndex: {
+							Description: "The last raft index of the server.",
+							Type:        schema.TypeInt,
+							Computed:    true,
+						},
+						argStableSince: {
+							Description: "Since when the server is healthy.",
+							Type:        schema.TypeString,
+							Computed:    true,
+						},
+						argVersion: {
+							Description: "The Vault version of the server.",
+							Type:        schema.TypeString,
+							Computed:    true,
+						},
+						argNodeType: {
+							Description: "The node type of the server. Vault Enterprise only.",
+							Type:        schema.TypeString,
+							Computed:    true,
+						},
+					},
+				},
+			},
+		},
+	}
+}
+
+func dataSourceRaftAutopilotStateRead(ctx context.Context, d *schema.ResourceData, meta interface{}) diag.Diagnostics {
+	client, diags := meta.(*apiClient).connection(ctx, d)
+	if diags.HasError() {
+		return diags
+	}
+
+	vaultClient, closeVault, diags := client.vault(ctx)
+	if diags.HasError() {
+		return diags
+	}
+	defer closeVault()
+
+	d.SetId(client.url)
+
+	state, err := vaultClient.Sys().RaftAutopilotStateWithContext(ctx)
+	if err != nil {
+		logError("failed to read the autopilot state: %v", err)
+		return diag.FromErr(err)
+	}
+	if state == nil {
+		return diag.Errorf("autopilot is not available, Vault does not use raft storage")
+	}
+
+	ids := make([]string, 0, len(state.Servers))
+	for id := range state.Servers {
+		ids = append(ids, id)
+	}
+	sort.Strings(ids)
+
+	servers := make([]interface{}, len(ids))
+	for i, id := range ids {
+		s := state.Servers[id]
+		servers[i] = map[string]interface{}{
+			argID:          s.ID,
+			argName:        s.Name,
+			argAddress:     s.Address,
+			argNodeStatus:  s.NodeStatus,
+			argStatus:      s.Status,
+			argHealthy:     s.Healthy,
+			argLastContact: s.LastContact,
+			argLastTerm:    int(s.LastTerm),
+			argLastIndex:   int(s.LastIndex),
+			argStableSince: s.StableSince,
+			argVersion:     s.Version,
+			argNodeType:    s.NodeType,
+		}
+	}
+
+	values := map[string]interface{}{
+		argHealthy:                    state.Healthy,
+		argFailureTolerance:           state.FailureTolerance,
+		argOptimisticFailureTolerance: state.OptimisticFailureTolerance,
+		argLeader:                     state.Leader,
+		argVoters:                     state.Voters,
+		argNonVoters:                  state.NonVoters,
+		argServers:                    servers,
+	}
+	for k, v := range values {
+		if err := d.Set(k, v); err != nil {
+			return diag.FromErr(err)
+		}
+	}
+
+	return diag.Diagnostics{}
+}
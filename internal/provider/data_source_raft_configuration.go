@@ -0,0 +1,49 @@
+package provider
+
+import (
+	"context"
+
+	"github.com/hashicorp/terraform-plugin-sdk/v2/diag"
+	"github.com/hashicorp/terraform-plugin-sdk/v2/helper/schema"
+)
+
+func dataSourceRaftConfiguration() *schema.Resource {
+	return &schema.Resource{
+		// This description is used by the documentation generator and the language server.
+		Description: "Data source for vault operator raft list-peers. It requires a token.",
+
+		ReadContext: dataSourceRaftConfigurationRead,
+
+		Schema: map[string]*schema.Schema{
+			argVaultConnection: connectionSchema(),
+			argServers:         raftServersSchema(),
+		},
+	}
+}
+
+func dataSourceRaftConfigurationRead(ctx context.Context, d *schema.ResourceData, meta interface{}) diag.Diagnostics {
+	client, diags := meta.(*apiClient).connection(ctx, d)
+	if diags.HasError() {
+		return diags
+	}
+
+	vaultClient, closeVault, diags := client.vault(ctx)
+	if diags.HasError() {
+		return diags
+	}
+	defer closeVault()
+
+	d.SetId(client.url)
+
+	servers, err := raftConfiguration(ctx, vaultClient)
+	if err != nil {
+		logError("%v", err)
+		return diag.FromErr(err)
+	}
+
+	if err := d.Set(argServers, flattenRaftServers(servers)); err != nil {
+		return diag.FromErr(err)
+	}
+
+	return diag.Diagnostics{}
+}
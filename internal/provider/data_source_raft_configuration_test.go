@@ -0,0 +1,43 @@
+package provider
+
+import (
+	"fmt"
+	"testing"
+
+	"github.com/hashicorp/terraform-plugin-sdk/v2/helper/resource"
+)
+
+var testAccDataSourceRaftConfigurationVar = fmt.Sprintf("data.%[1]s.test", dsRaftConfig)
+
+func testAccDataSourceRaftConfiguration(addr, token string) string {
+	return fmt.Sprintf(`
+provider "%[1]s" {
+    vault_addr  = "%[3]s"
+    vault_token = "%[4]s"
+}
+
+data "%[2]s" "test" {
+}
+`, provider, dsRaftConfig, addr, token)
+}
+
+func TestAccDataSourceRaftConfiguration(t *testing.T) {
+	addr := startRaftVault(t, "node-1")
+	token := initRaftVault(t, addr)
+
+	resource.UnitTest(t, resource.TestCase{
+		PreCheck:          func() { testAccPreCheck(t) },
+		ProviderFactories: providerFactories,
+		Steps: []resource.TestStep{
+			{
+				Config: testAccDataSourceRaftConfiguration(addr, token),
+				Check: resource.ComposeTestCheckFunc(
+					resource.TestCheckResourceAttr(testAccDataSourceRaftConfigurationVar, "servers.#", "1"),
+					resource.TestCheckResourceAttr(testAccDataSourceRaftConfigurationVar, "servers.0.node_id", "node-1"),
+					resource.TestCheckResourceAttr(testAccDataSourceRaftConfigurationVar, "servers.0.leader", "true"),
+					resource.TestCheckResourceAttr(testAccDataSourceRaftConfigurationVar, "servers.0.voter", "true"),
+				),
+			},
+		},
+	})
+}
@@ -1,10 +1,13 @@
 package provider
 
 import (
+	"context"
 	"fmt"
+	"reflect"
 	"testing"
 
 	"github.com/hashicorp/terraform-plugin-sdk/v2/helper/resource"
+	"github.com/hashicorp/terraform-plugin-sdk/v2/helper/schema"
 )
 
 var testAccDataSourceInitVar = fmt.Sprintf("data.%[1]s.test", resInit)
@@ -52,3 +55,26 @@ func TestAccDataSourceInitComplete(t *testing.T) {
 		},
 	})
 }
+
+func TestDataSourceInit_portForward(t *testing.T) {
+	vault := newFakeVault(t)
+	vault.reply("sys/init", func() interface{} {
+		return map[string]interface{}{"initialized": true}
+	})
+
+	// The local port is picked by the OS, so Vault is only reached through
+	// the port forward
+	client, forwarded := startKubeVault(t, map[string]*fakeVault{"vault-0": vault})
+
+	d := schema.TestResourceDataRaw(t, providerDatasource().Schema, map[string]interface{}{})
+	if diags := providerDatasourceRead(context.TODO(), d, client); diags.HasError() {
+		t.Fatal(diags)
+	}
+
+	if want := []string{"vault-0"}; !reflect.DeepEqual(*forwarded, want) {
+		t.Errorf("expected a port forward to %v, got %v", want, *forwarded)
+	}
+	if !d.Get(argInitialized).(bool) {
+		t.Error("expected Vault to be initialized")
+	}
+}
@@ -0,0 +1,92 @@
+package provider
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"github.com/hashicorp/vault/api"
+)
+
+const (
+	pathHealth = "sys/health"
+
+	healthPollInterval = 2 * time.Second
+	leaderPollInterval = time.Second
+)
+
+// waitHealth reads the health of the node until reached tells it is in the
+// state waited for, described by state, or deadline.
+func waitHealth(ctx context.Context, c *api.Client, params map[string][]string, state string, reached func(health *api.HealthResponse, code int) bool, deadline time.Time) (*api.HealthResponse, int, error) {
+	for {
+		health, code, err := readHealth(ctx, c, params)
+		if err == nil && reached(health, code) {
+			return health, code, nil
+		}
+		if err != nil {
+			logDebug("failed to read the health: %v", err)
+		}
+
+		if time.Now().After(deadline) {
+			return nil, 0, fmt.Errorf("timed out waiting for Vault to be %s", state)
+		}
+
+		select {
+		case <-ctx.Done():
+			return nil, 0, ctx.Err()
+		case <-time.After(healthPollInterval):
+		}
+	}
+}
+
+// readHealth reads the health of the node with the status code it answers,
+// which is an error status for a sealed or uninitialized node by default.
+func readHealth(ctx context.Context, c *api.Client, params map[string][]string) (*api.HealthResponse, int, error) {
+	// sys/health answers with 5xx codes by design, which must not be retried
+	c, err := c.Clone()
+	if err != nil {
+		return nil, 0, err
+	}
+	c.SetMaxRetries(0)
+
+	resp, err := c.Logical().ReadRawWithDataWithContext(ctx, pathHealth, params)
+	if resp == nil {
+		return nil, 0, err
+	}
+	defer resp.Body.Close()
+
+	var health api.HealthResponse
+	if decodeErr := resp.DecodeJSON(&health); decodeErr != nil {
+		if err != nil {
+			return nil, 0, err
+		}
+		return nil, 0, fmt.Errorf("failed to decode the health: %w", decodeErr)
+	}
+
+	return &health, resp.StatusCode, nil
+}
+
+// waitNewLeader polls the leader until another node than previous is
+// active, or deadline. An empty previous waits for any active node.
+func waitNewLeader(ctx context.Context, c *api.Client, previous string, deadline time.Time) (*api.LeaderResponse, error) {
+	for {
+		leader, err := c.Sys().LeaderWithContext(ctx)
+		if err == nil && leader.LeaderAddress != "" && leader.LeaderAddress != previous {
+			logInfo("%s is the active node", leader.LeaderAddress)
+			return leader, nil
+		}
+
+		if time.Now().After(deadline) {
+			if previous == "" {
+				return nil, fmt.Errorf("timed out waiting for a node to become active")
+			}
+			return nil, fmt.Errorf("timed out waiting for another node than %s to become active", previous)
+		}
+
+		select {
+		case <-ctx.Done():
+			return nil, ctx.Err()
+		case <-time.After(leaderPollInterval):
+		}
+	}
+}
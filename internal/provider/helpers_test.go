@@ -3,23 +3,45 @@ package provider
 import (
 	"bufio"
 	"bytes"
+	"context"
 	"crypto/rand"
 	"crypto/rsa"
 	"crypto/x509"
 	"crypto/x509/pkix"
+	"encoding/json"
 	"encoding/pem"
 	"fmt"
 	"io"
 	"math/big"
 	"net"
+	"net/http"
+	"net/http/httptest"
 	"os"
 	"os/exec"
 	"path/filepath"
 	"regexp"
 	"strconv"
+	"strings"
+	"sync"
 	"testing"
 	"text/template"
 	"time"
+
+	"github.com/hashicorp/terraform-plugin-sdk/v2/diag"
+	"github.com/hashicorp/terraform-plugin-sdk/v2/helper/schema"
+	"github.com/hashicorp/terraform-plugin-sdk/v2/terraform"
+	"github.com/hashicorp/vault/api"
+	"golang.org/x/crypto/ssh"
+	authorizationv1 "k8s.io/api/authorization/v1"
+	v1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/util/httpstream"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/util/httpstream/spdy"
+	"k8s.io/client-go/kubernetes/fake"
+	restclient "k8s.io/client-go/rest"
+	"k8s.io/client-go/tools/clientcmd"
+	clientcmdapi "k8s.io/client-go/tools/clientcmd/api"
+	"k8s.io/client-go/tools/portforward"
 )
 
 func startVault(t *testing.T, enableTLS bool) {
@@ -111,6 +133,31 @@ func startVault(t *testing.T, enableTLS bool) {
 		t.Fatal(err)
 	}
 
+	clusterAddress := regexp.MustCompile("cluster address: \"(.*?)\"")
+
+	runVault(t, configPath, func(line string) {
+		if match := clusterAddress.FindStringSubmatch(line); match != nil {
+			_, clusterPort, err := net.SplitHostPort(match[1])
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			port, err := strconv.Atoi(clusterPort)
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			t.Setenv("VAULT_ADDR", fmt.Sprintf("%s://localhost:%d", protocol, port-1))
+		}
+	})
+}
+
+// runVault starts a Vault server with the given configuration, passing every
+// line it logs until it is started to scan, and stops it at the end of the
+// test.
+func runVault(t *testing.T, configPath string, scan func(line string)) {
+	t.Helper()
+
 	cmd := exec.Command("vault", "server", "-config", configPath)
 
 	stdout, err := cmd.StdoutPipe()
@@ -127,23 +174,10 @@ func startVault(t *testing.T, enableTLS bool) {
 		t.Fatal(err)
 	}
 
-	clusterAddress := regexp.MustCompile("cluster address: \"(.*?)\"")
 	vaultStarted := regexp.MustCompile("Vault server started!")
 
 	for scanner.Scan() {
-		if match := clusterAddress.FindStringSubmatch(scanner.Text()); match != nil {
-			_, clusterPort, err := net.SplitHostPort(match[1])
-			if err != nil {
-				t.Fatal(err)
-			}
-
-			port, err := strconv.Atoi(clusterPort)
-			if err != nil {
-				t.Fatal(err)
-			}
-
-			t.Setenv("VAULT_ADDR", fmt.Sprintf("%s://localhost:%d", protocol, port-1))
-		}
+		scan(scanner.Text())
 
 		if vaultStarted.MatchString(scanner.Text()) {
 			t.Cleanup(stopVault(t, cmd))
@@ -170,6 +204,98 @@ func startVault(t *testing.T, enableTLS bool) {
 	t.Error("Unable to start Vault server")
 }
 
+// startRaftVault starts a Vault server with raft storage, as node nodeID,
+// and returns its address. The node is neither initialized nor part of a
+// cluster.
+func startRaftVault(t *testing.T, nodeID string) string {
+	t.Helper()
+
+	dataDir := t.TempDir()
+	configPath := filepath.Join(dataDir, "vault.hcl")
+
+	configTemplate, err := template.ParseFiles("../../vault-raft.hcl")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	config := struct {
+		APIPort     int
+		ClusterPort int
+		DataDir     string
+		NodeID      string
+	}{
+		APIPort:     freePort(t),
+		ClusterPort: freePort(t),
+		DataDir:     dataDir,
+		NodeID:      nodeID,
+	}
+
+	configFile, err := os.Create(configPath)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer configFile.Close()
+
+	if err := configTemplate.Execute(configFile, config); err != nil {
+		t.Fatal(err)
+	}
+
+	runVault(t, configPath, func(string) {})
+
+	return fmt.Sprintf("http://127.0.0.1:%d", config.APIPort)
+}
+
+// initRaftVault initializes and unseals the raft node at addr, which then
+// leads a single node cluster, and returns the root token.
+func initRaftVault(t *testing.T, addr string) string {
+	t.Helper()
+
+	ctx := context.TODO()
+
+	config := api.DefaultConfig()
+	config.Address = addr
+	c, err := api.NewClient(config)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	res, err := c.Sys().InitWithContext(ctx, &api.InitRequest{SecretShares: 1, SecretThreshold: 1})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if _, err := c.Sys().UnsealWithContext(ctx, res.KeysB64[0]); err != nil {
+		t.Fatal(err)
+	}
+
+	c.SetToken(res.RootToken)
+
+	deadline := time.Now().Add(30 * time.Second)
+	for {
+		leader, err := c.Sys().LeaderWithContext(ctx)
+		if err == nil && leader.IsSelf {
+			return res.RootToken
+		}
+		if time.Now().After(deadline) {
+			t.Fatalf("%s did not become the raft leader: %v", addr, err)
+		}
+		time.Sleep(500 * time.Millisecond)
+	}
+}
+
+// freePort returns a local TCP port nothing listens on.
+func freePort(t *testing.T) int {
+	t.Helper()
+
+	l, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer l.Close()
+
+	return l.Addr().(*net.TCPAddr).Port
+}
+
 func stopVault(t *testing.T, cmd *exec.Cmd) func() {
 	return func() {
 		if err := cmd.Process.Kill(); err != nil {
@@ -185,3 +311,538 @@ func stopVault(t *testing.T, cmd *exec.Cmd) func() {
 		}
 	}
 }
+
+// configureNow configures the provider, and runs the configuration it
+// defers to the first use of the client.
+func configureNow(ctx context.Context, p *schema.Provider, rc *terraform.ResourceConfig) diag.Diagnostics {
+	if diags := p.Configure(ctx, rc); diags.HasError() {
+		return diags
+	}
+
+	return p.Meta().(*apiClient).configure(ctx)
+}
+
+// testProviderMeta starts a Vault server answering with handler, and returns
+// the meta of a provider configured to talk to it.
+func testProviderMeta(t *testing.T, handler http.Handler) interface{} {
+	t.Helper()
+
+	server := httptest.NewServer(handler)
+	t.Cleanup(server.Close)
+
+	p := New("dev")()
+	diags := configureNow(context.TODO(), p, terraform.NewResourceConfigRaw(map[string]interface{}{
+		argVaultAddr: server.URL,
+	}))
+	if diags.HasError() {
+		t.Fatal(diags)
+	}
+
+	return p.Meta()
+}
+
+// fakeVault is a fake Vault node for the unit tests of resources and data
+// sources, which register the handlers of the endpoints they use.
+type fakeVault struct {
+	t   *testing.T
+	mux *http.ServeMux
+}
+
+func newFakeVault(t *testing.T) *fakeVault {
+	return &fakeVault{t: t, mux: http.NewServeMux()}
+}
+
+// handle registers handler for path of the Vault API, like sys/leader.
+func (v *fakeVault) handle(path string, handler http.HandlerFunc) {
+	v.mux.HandleFunc("/v1/"+path, handler)
+}
+
+// reply registers a handler answering path with the JSON encoding of the
+// value returned by reply.
+func (v *fakeVault) reply(path string, reply func() interface{}) {
+	v.handle(path, func(w http.ResponseWriter, r *http.Request) {
+		json.NewEncoder(w).Encode(reply())
+	})
+}
+
+// decode decodes the JSON body of a request into body.
+func (v *fakeVault) decode(r *http.Request, body interface{}) {
+	if err := json.NewDecoder(r.Body).Decode(body); err != nil {
+		v.t.Error(err)
+	}
+}
+
+// meta starts the fake Vault, and returns the meta of a provider talking to
+// it.
+func (v *fakeVault) meta() interface{} {
+	v.t.Helper()
+
+	return testProviderMeta(v.t, v.mux)
+}
+
+// raftConfigurationReply is the reply of sys/storage/raft/configuration
+// listing servers.
+func raftConfigurationReply(servers []raftServer) interface{} {
+	return map[string]interface{}{
+		"data": map[string]interface{}{
+			"config": map[string]interface{}{"servers": servers},
+		},
+	}
+}
+
+// startKubeAPI starts a fake Kubernetes API server that allows every
+// SelfSubjectAccessReview, passes any other request to handler when there is
+// one, and records the Authorization header of the requests it receives.
+// Credentials are only sent over TLS, so it returns the PEM encoded
+// certificate of the server too.
+func startKubeAPI(t *testing.T, handler http.Handler) (*httptest.Server, string, *[]string) {
+	t.Helper()
+
+	var authorizations []string
+
+	server := httptest.NewTLSServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		authorizations = append(authorizations, r.Header.Get("Authorization"))
+
+		if r.Method != http.MethodPost || r.URL.Path != "/apis/authorization.k8s.io/v1/selfsubjectaccessreviews" {
+			if handler != nil {
+				handler.ServeHTTP(w, r)
+			} else {
+				http.NotFound(w, r)
+			}
+			return
+		}
+
+		var review authorizationv1.SelfSubjectAccessReview
+		if err := json.NewDecoder(r.Body).Decode(&review); err != nil {
+			t.Error(err)
+		}
+		review.Status.Allowed = true
+
+		w.Header().Set("Content-Type", "application/json")
+		json.NewEncoder(w).Encode(&review)
+	}))
+	t.Cleanup(server.Close)
+
+	ca := pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: server.Certificate().Raw})
+
+	return server, string(ca), &authorizations
+}
+
+// startPortForwardAPI starts a fake Kubernetes API server serving the port
+// forwards of pods, each to the address of backends named after the pod. It
+// records the pods forwarded to.
+func startPortForwardAPI(t *testing.T, backends map[string]string) (*httptest.Server, *[]string) {
+	t.Helper()
+
+	var mu sync.Mutex
+	var forwarded []string
+
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		// /api/v1/namespaces/<namespace>/pods/<pod>/portforward
+		parts := strings.Split(r.URL.Path, "/")
+		if len(parts) != 8 || parts[7] != "portforward" {
+			http.NotFound(w, r)
+			return
+		}
+		backend, ok := backends[parts[6]]
+		if !ok {
+			http.NotFound(w, r)
+			return
+		}
+
+		if _, err := httpstream.Handshake(r, w, []string{portforward.PortForwardProtocolV1Name}); err != nil {
+			t.Error(err)
+			return
+		}
+
+		mu.Lock()
+		forwarded = append(forwarded, parts[6])
+		mu.Unlock()
+
+		conn := spdy.NewResponseUpgrader().UpgradeResponse(w, r, func(stream httpstream.Stream, replySent <-chan struct{}) error {
+			go func() {
+				<-replySent
+				defer stream.Close()
+
+				// There is no error to report on error streams
+				if stream.Headers().Get(v1.StreamType) != v1.StreamTypeData {
+					return
+				}
+
+				c, err := net.Dial("tcp", backend)
+				if err != nil {
+					t.Error(err)
+					return
+				}
+				defer c.Close()
+
+				go func() {
+					io.Copy(c, stream)
+					c.(*net.TCPConn).CloseWrite()
+				}()
+				io.Copy(stream, c)
+			}()
+			return nil
+		})
+		if conn != nil {
+			<-conn.CloseChan()
+		}
+	}))
+	t.Cleanup(server.Close)
+
+	return server, &forwarded
+}
+
+// startKubeVault starts the fake Vault nodes of vaults, keyed by pod name,
+// behind the service vault/vault along with the pending pods, and returns a
+// client reaching them through port forwards like a provider configured with
+// kube_config. It also returns the pods forwarded to.
+func startKubeVault(t *testing.T, vaults map[string]*fakeVault, pending ...string) (*apiClient, *[]string) {
+	t.Helper()
+
+	svc := testService(v1.ServicePort{Name: "http", Port: 8200})
+	objects := []runtime.Object{svc}
+	backends := map[string]string{}
+
+	for name, vault := range vaults {
+		server := httptest.NewServer(vault.mux)
+		t.Cleanup(server.Close)
+		backends[name] = server.Listener.Addr().String()
+
+		pod := testPod(name, v1.PodRunning, svc.Spec.Selector)
+		objects = append(objects, &pod)
+	}
+	for _, name := range pending {
+		pod := testPod(name, v1.PodPending, svc.Spec.Selector)
+		objects = append(objects, &pod)
+	}
+
+	kubeAPI, forwarded := startPortForwardAPI(t, backends)
+
+	vaultClient, err := api.NewClient(api.DefaultConfig())
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	client := &apiClient{
+		client: vaultClient,
+		url:    "http://localhost:0",
+		kubeConn: kubeConn{
+			nameSpace:    "vault",
+			serviceName:  "vault",
+			localPort:    "0",
+			remotePort:   "8200",
+			scheme:       "http",
+			podSelection: podSelectionAny,
+			transport:    transportPortForward,
+			kubeConfig:   &restclient.Config{Host: kubeAPI.URL},
+			kubeClient:   fake.NewSimpleClientset(objects...),
+		},
+	}
+
+	return client, forwarded
+}
+
+// writeKubeConfig writes a Kubernetes config with one context per token,
+// all pointing to server, and returns its path.
+func writeKubeConfig(t *testing.T, server, ca, currentContext string, tokens map[string]string) string {
+	t.Helper()
+
+	config := clientcmdapi.NewConfig()
+	config.Clusters["test"] = &clientcmdapi.Cluster{Server: server, CertificateAuthorityData: []byte(ca)}
+	for name, token := range tokens {
+		config.AuthInfos[name] = &clientcmdapi.AuthInfo{Token: token}
+		config.Contexts[name] = &clientcmdapi.Context{Cluster: "test", AuthInfo: name}
+	}
+	config.CurrentContext = currentContext
+
+	path := filepath.Join(t.TempDir(), "config")
+	if err := clientcmd.WriteToFile(*config, path); err != nil {
+		t.Fatal(err)
+	}
+
+	return path
+}
+
+// startSSHServer starts an SSH server accepting the given client key, which
+// forwards direct-tcpip channels like a bastion does. It returns its address
+// and host key, and records the addresses it forwards to.
+func startSSHServer(t *testing.T, clientKey ssh.PublicKey) (string, ssh.PublicKey, *[]string) {
+	t.Helper()
+
+	hostKey, err := ssh.NewSignerFromKey(testKey(t))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	config := &ssh.ServerConfig{
+		PublicKeyCallback: func(_ ssh.ConnMetadata, key ssh.PublicKey) (*ssh.Permissions, error) {
+			if !bytes.Equal(key.Marshal(), clientKey.Marshal()) {
+				return nil, fmt.Errorf("unknown public key")
+			}
+			return nil, nil
+		},
+	}
+	config.AddHostKey(hostKey)
+
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { listener.Close() })
+
+	var mu sync.Mutex
+	var forwards []string
+
+	go func() {
+		for {
+			conn, err := listener.Accept()
+			if err != nil {
+				return
+			}
+
+			go func() {
+				_, chans, reqs, err := ssh.NewServerConn(conn, config)
+				if err != nil {
+					return
+				}
+				go ssh.DiscardRequests(reqs)
+
+				for newChannel := range chans {
+					if newChannel.ChannelType() != "direct-tcpip" {
+						newChannel.Reject(ssh.UnknownChannelType, "unsupported channel type")
+						continue
+					}
+
+					var target struct {
+						Host       string
+						Port       uint32
+						OriginHost string
+						OriginPort uint32
+					}
+					if err := ssh.Unmarshal(newChannel.ExtraData(), &target); err != nil {
+						newChannel.Reject(ssh.ConnectionFailed, err.Error())
+						continue
+					}
+
+					addr := net.JoinHostPort(target.Host, strconv.Itoa(int(target.Port)))
+					mu.Lock()
+					forwards = append(forwards, addr)
+					mu.Unlock()
+
+					upstream, err := net.Dial("tcp", addr)
+					if err != nil {
+						newChannel.Reject(ssh.ConnectionFailed, err.Error())
+						continue
+					}
+
+					channel, requests, err := newChannel.Accept()
+					if err != nil {
+						upstream.Close()
+						continue
+					}
+					go ssh.DiscardRequests(requests)
+
+					go func() {
+						io.Copy(channel, upstream)
+						channel.Close()
+					}()
+					go func() {
+						io.Copy(upstream, channel)
+						upstream.Close()
+					}()
+				}
+			}()
+		}
+	}()
+
+	return listener.Addr().String(), hostKey.PublicKey(), &forwards
+}
+
+// testKey generates an RSA key for tests.
+func testKey(t *testing.T) *rsa.PrivateKey {
+	t.Helper()
+
+	key, err := rsa.GenerateKey(rand.Reader, 2048)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	return key
+}
+
+// startSOCKS5Proxy starts a SOCKS5 proxy, requiring the given credentials
+// when username is set, and resolving host names from hosts first. It returns
+// its address, and records the addresses it is asked to connect to.
+func startSOCKS5Proxy(t *testing.T, username, password string, hosts map[string]string) (string, *[]string) {
+	t.Helper()
+
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { listener.Close() })
+
+	var mu sync.Mutex
+	var connects []string
+
+	handshake := func(conn net.Conn) (string, error) {
+		buf := make([]byte, 256)
+
+		// Greeting: version, number of methods, methods
+		if _, err := io.ReadFull(conn, buf[:2]); err != nil {
+			return "", err
+		}
+		if _, err := io.ReadFull(conn, buf[:buf[1]]); err != nil {
+			return "", err
+		}
+
+		if username == "" {
+			if _, err := conn.Write([]byte{5, 0}); err != nil {
+				return "", err
+			}
+		} else {
+			if _, err := conn.Write([]byte{5, 2}); err != nil {
+				return "", err
+			}
+
+			// Username/password authentication, RFC 1929
+			if _, err := io.ReadFull(conn, buf[:2]); err != nil {
+				return "", err
+			}
+			user := make([]byte, buf[1])
+			if _, err := io.ReadFull(conn, user); err != nil {
+				return "", err
+			}
+			if _, err := io.ReadFull(conn, buf[:1]); err != nil {
+				return "", err
+			}
+			pass := make([]byte, buf[0])
+			if _, err := io.ReadFull(conn, pass); err != nil {
+				return "", err
+			}
+			if string(user) != username || string(pass) != password {
+				conn.Write([]byte{1, 1})
+				return "", fmt.Errorf("invalid credentials")
+			}
+			if _, err := conn.Write([]byte{1, 0}); err != nil {
+				return "", err
+			}
+		}
+
+		// Request: version, command, reserved, address type, address, port
+		if _, err := io.ReadFull(conn, buf[:4]); err != nil {
+			return "", err
+		}
+		var host string
+		switch buf[3] {
+		case 1:
+			if _, err := io.ReadFull(conn, buf[:4]); err != nil {
+				return "", err
+			}
+			host = net.IP(buf[:4]).String()
+		case 3:
+			if _, err := io.ReadFull(conn, buf[:1]); err != nil {
+				return "", err
+			}
+			name := make([]byte, buf[0])
+			if _, err := io.ReadFull(conn, name); err != nil {
+				return "", err
+			}
+			host = string(name)
+		default:
+			return "", fmt.Errorf("unsupported address type %d", buf[3])
+		}
+		if _, err := io.ReadFull(conn, buf[:2]); err != nil {
+			return "", err
+		}
+
+		return net.JoinHostPort(host, strconv.Itoa(int(buf[0])<<8|int(buf[1]))), nil
+	}
+
+	go func() {
+		for {
+			conn, err := listener.Accept()
+			if err != nil {
+				return
+			}
+
+			go func() {
+				defer conn.Close()
+
+				addr, err := handshake(conn)
+				if err != nil {
+					return
+				}
+
+				mu.Lock()
+				connects = append(connects, addr)
+				mu.Unlock()
+
+				host, port, _ := net.SplitHostPort(addr)
+				if ip, ok := hosts[host]; ok {
+					addr = net.JoinHostPort(ip, port)
+				}
+
+				upstream, err := net.Dial("tcp", addr)
+				if err != nil {
+					conn.Write([]byte{5, 5, 0, 1, 0, 0, 0, 0, 0, 0})
+					return
+				}
+				defer upstream.Close()
+
+				if _, err := conn.Write([]byte{5, 0, 0, 1, 0, 0, 0, 0, 0, 0}); err != nil {
+					return
+				}
+
+				go io.Copy(upstream, conn)
+				io.Copy(conn, upstream)
+			}()
+		}
+	}()
+
+	return listener.Addr().String(), &connects
+}
+
+// startConnectProxy starts an HTTP proxy tunnelling CONNECT requests, and
+// records the addresses it connects to.
+func startConnectProxy(t *testing.T) (*httptest.Server, *[]string) {
+	t.Helper()
+
+	var mu sync.Mutex
+	var connects []string
+
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != http.MethodConnect {
+			http.Error(w, "only CONNECT is supported", http.StatusMethodNotAllowed)
+			return
+		}
+
+		mu.Lock()
+		connects = append(connects, r.Host)
+		mu.Unlock()
+
+		upstream, err := net.Dial("tcp", r.Host)
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusBadGateway)
+			return
+		}
+		defer upstream.Close()
+
+		conn, _, err := w.(http.Hijacker).Hijack()
+		if err != nil {
+			return
+		}
+		defer conn.Close()
+
+		if _, err := conn.Write([]byte("HTTP/1.1 200 Connection established\r\n\r\n")); err != nil {
+			return
+		}
+
+		go io.Copy(upstream, conn)
+		io.Copy(conn, upstream)
+	}))
+	t.Cleanup(server.Close)
+
+	return server, &connects
+}
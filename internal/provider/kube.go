@@ -0,0 +1,533 @@
+package provider
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/url"
+	"os"
+	"strconv"
+	"strings"
+	"sync"
+
+	"github.com/hashicorp/vault/api"
+	authorizationv1 "k8s.io/api/authorization/v1"
+	v1 "k8s.io/api/core/v1"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/util/httpstream"
+	"k8s.io/apimachinery/pkg/util/intstr"
+	restclient "k8s.io/client-go/rest"
+	"k8s.io/client-go/tools/portforward"
+	"k8s.io/client-go/transport/spdy"
+)
+
+const (
+	podSelectionAny           = "any"
+	podSelectionActive        = "active"
+	podSelectionUninitialized = "uninitialized"
+	podSelectionName          = "name"
+	podSelectionOrdinal       = "ordinal"
+
+	transportPortForward  = "port_forward"
+	transportServiceProxy = "service_proxy"
+
+	// Labels maintained by Vault's Kubernetes service registration, which the
+	// Vault Helm chart enables by default
+	labelVaultActive      = "vault-active"
+	labelVaultInitialized = "vault-initialized"
+	labelVaultSealed      = "vault-sealed"
+)
+
+var podSelections = []string{
+	podSelectionAny,
+	podSelectionActive,
+	podSelectionUninitialized,
+	podSelectionName,
+	podSelectionOrdinal,
+}
+
+var transports = []string{
+	transportPortForward,
+	transportServiceProxy,
+}
+
+// podState is the Vault state of a single pod
+type podState struct {
+	Initialized bool `json:"initialized"`
+	Sealed      bool `json:"sealed"`
+	Standby     bool `json:"standby"`
+}
+
+func (s podState) active() bool {
+	return s.Initialized && !s.Sealed && !s.Standby
+}
+
+// kubePermission is a Kubernetes API permission the provider needs in the
+// Vault namespace
+type kubePermission struct {
+	verb        string
+	resource    string
+	subresource string
+}
+
+func (p kubePermission) String() string {
+	if p.subresource != "" {
+		return fmt.Sprintf("%s %s/%s", p.verb, p.resource, p.subresource)
+	}
+	return fmt.Sprintf("%s %s", p.verb, p.resource)
+}
+
+// requiredPermissions lists the permissions needed to reach Vault through
+// the Kubernetes API with the current configuration.
+func (k *kubeConn) requiredPermissions() []kubePermission {
+	if k.transport == transportServiceProxy {
+		// The API server maps the HTTP method of proxied requests to the verb
+		return []kubePermission{
+			{verb: "get", resource: "services", subresource: "proxy"},
+			{verb: "create", resource: "services", subresource: "proxy"},
+			{verb: "update", resource: "services", subresource: "proxy"},
+		}
+	}
+
+	permissions := []kubePermission{
+		{verb: "get", resource: "services"},
+		{verb: "list", resource: "pods"},
+		{verb: "create", resource: "pods", subresource: "portforward"},
+	}
+
+	// Without the service registration labels, the state of the pods is
+	// probed through the API server
+	if k.podSelection == podSelectionActive || k.podSelection == podSelectionUninitialized {
+		permissions = append(permissions, kubePermission{verb: "get", resource: "pods", subresource: "proxy"})
+	}
+
+	if k.caSecret != "" {
+		permissions = append(permissions, kubePermission{verb: "get", resource: "secrets"})
+	}
+
+	return permissions
+}
+
+// checkPermissions asks the API server, through SelfSubjectAccessReviews,
+// whether the Kubernetes identity has every required permission, so missing
+// RBAC rules are reported up front rather than as failed port forwards.
+func (k *kubeConn) checkPermissions(ctx context.Context) error {
+	var missing []string
+
+	for _, p := range k.requiredPermissions() {
+		review := &authorizationv1.SelfSubjectAccessReview{
+			Spec: authorizationv1.SelfSubjectAccessReviewSpec{
+				ResourceAttributes: &authorizationv1.ResourceAttributes{
+					Namespace:   k.nameSpace,
+					Verb:        p.verb,
+					Resource:    p.resource,
+					Subresource: p.subresource,
+				},
+			},
+		}
+
+		res, err := k.kubeClient.AuthorizationV1().SelfSubjectAccessReviews().Create(ctx, review, metav1.CreateOptions{})
+		if err != nil {
+			return fmt.Errorf("failed to review Kubernetes permissions: %w", err)
+		}
+
+		if !res.Status.Allowed {
+			logDebug("Kubernetes permission %s denied: %s", p, res.Status.Reason)
+			missing = append(missing, p.String())
+		}
+	}
+
+	if len(missing) > 0 {
+		return &missingPermissionsError{namespace: k.nameSpace, permissions: missing}
+	}
+
+	return nil
+}
+
+// portForward is a running port forward to a single Vault pod
+type portForward struct {
+	pod       string
+	localPort uint16
+	stopCh    chan struct{}
+	stopOnce  sync.Once
+}
+
+// close terminates the port forward
+func (f *portForward) close() {
+	f.stopOnce.Do(func() {
+		close(f.stopCh)
+	})
+}
+
+// recordingDialer keeps the error of the connection upgrade, which the port
+// forwarder only reports as text.
+type recordingDialer struct {
+	httpstream.Dialer
+	err error
+}
+
+func (d *recordingDialer) Dial(protocols ...string) (httpstream.Connection, string, error) {
+	conn, protocol, err := d.Dialer.Dial(protocols...)
+	d.err = err
+	return conn, protocol, err
+}
+
+// portForwarding reports whether Vault is reached through port forwards to
+// its pods.
+func (k *kubeConn) portForwarding() bool {
+	return k.kubeConfig != nil && k.transport == transportPortForward
+}
+
+// serviceProxyURL returns the URL of the Vault service through the service
+// proxy of the Kubernetes API server.
+func (k *kubeConn) serviceProxyURL() (string, error) {
+	host, err := url.Parse(k.kubeConfig.Host)
+	if err != nil {
+		return "", fmt.Errorf("failed to parse the Kubernetes API host: %w", err)
+	}
+
+	host.Path = strings.TrimSuffix(host.Path, "/") + fmt.Sprintf("/api/v1/namespaces/%s/services/%s:%s:%s/proxy",
+		k.nameSpace, k.scheme, k.serviceName, k.remotePort)
+
+	return host.String(), nil
+}
+
+// serviceProxyTransport sends the Vault API requests through the Kubernetes
+// API server, authenticated with the Kubernetes credentials
+type serviceProxyTransport struct {
+	// host is the Kubernetes API server, the only host requests may go to
+	host string
+	base http.RoundTripper
+}
+
+// newServiceProxyTransport returns the transport of the Vault client when it
+// talks to Vault through the service proxy.
+func (k *kubeConn) newServiceProxyTransport() (*serviceProxyTransport, error) {
+	host, err := url.Parse(k.kubeConfig.Host)
+	if err != nil {
+		return nil, fmt.Errorf("failed to parse the Kubernetes API host: %w", err)
+	}
+
+	base, err := restclient.TransportFor(k.kubeConfig)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create the Kubernetes API transport: %w", err)
+	}
+
+	return &serviceProxyTransport{host: host.Host, base: base}, nil
+}
+
+func (t *serviceProxyTransport) RoundTrip(req *http.Request) (*http.Response, error) {
+	// The Kubernetes credentials are added to every request, so never follow
+	// Vault somewhere else, e.g. when a standby redirects to the active node
+	if req.URL.Host != t.host {
+		return nil, fmt.Errorf("refusing to send a request to %s through the Kubernetes service proxy", req.URL.Host)
+	}
+
+	return t.base.RoundTrip(req)
+}
+
+// vaultPods looks up the Vault service and the pods behind it.
+func (k *kubeConn) vaultPods(ctx context.Context) (*v1.Service, *v1.PodList, error) {
+	svc, err := k.kubeClient.CoreV1().Services(k.nameSpace).Get(ctx, k.serviceName, metav1.GetOptions{})
+	switch {
+	case apierrors.IsNotFound(err):
+		return nil, nil, &serviceNotFoundError{namespace: k.nameSpace, service: k.serviceName}
+	case apierrors.IsForbidden(err):
+		return nil, nil, &forbiddenError{namespace: k.nameSpace, verb: "get", resource: "services", err: err}
+	case err != nil:
+		return nil, nil, fmt.Errorf("failed to get service %s/%s: %w", k.nameSpace, k.serviceName, err)
+	}
+
+	selector := mapToSelectorStr(svc.Spec.Selector)
+	if selector == "" {
+		return nil, nil, &emptySelectorError{namespace: k.nameSpace, service: k.serviceName}
+	}
+
+	pods, err := k.kubeClient.CoreV1().Pods(svc.Namespace).List(ctx, metav1.ListOptions{LabelSelector: selector})
+	switch {
+	case apierrors.IsForbidden(err):
+		return nil, nil, &forbiddenError{namespace: svc.Namespace, verb: "list", resource: "pods", err: err}
+	case err != nil:
+		return nil, nil, fmt.Errorf("failed to list pods of service %s/%s: %w", k.nameSpace, k.serviceName, err)
+	}
+
+	if len(pods.Items) == 0 {
+		return nil, nil, &noRunningPodsError{namespace: k.nameSpace, service: k.serviceName, selector: selector}
+	}
+
+	return svc, pods, nil
+}
+
+// forward opens a port forward from localPort to the Vault container port of
+// the pod. A localPort of "0" lets the OS pick a free port. The port forward
+// runs until it is closed or ctx is done.
+func (k *kubeConn) forward(ctx context.Context, svc *v1.Service, pod *v1.Pod, localPort string) (*portForward, error) {
+	podPort, err := targetPort(svc, pod, k.remotePort)
+	if err != nil {
+		return nil, err
+	}
+
+	serverURL, err := url.Parse(
+		fmt.Sprintf("%s/api/v1/namespaces/%s/pods/%s/portforward", k.kubeConfig.Host, pod.Namespace, pod.Name))
+	if err != nil {
+		return nil, fmt.Errorf("failed to construct server url: %w", err)
+	}
+
+	transport, upgrader, err := spdy.RoundTripperFor(k.kubeConfig)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create a round tripper: %w", err)
+	}
+
+	dialer := &recordingDialer{
+		Dialer: spdy.NewDialer(upgrader, &http.Client{Transport: transport}, http.MethodPost, serverURL),
+	}
+
+	f := &portForward{
+		pod:    pod.Name,
+		stopCh: make(chan struct{}),
+	}
+	// readyCh communicate when the port forward is ready to get traffic
+	readyCh := make(chan struct{})
+
+	pf, err := portforward.NewOnAddresses(
+		dialer,
+		[]string{"127.0.0.1"},
+		[]string{fmt.Sprintf("%s:%d", localPort, podPort)},
+		f.stopCh,
+		readyCh,
+		os.Stdout,
+		os.Stderr)
+	if err != nil {
+		return nil, &forwardFailedError{pod: pod.Name, err: err}
+	}
+
+	errCh := make(chan error, 1)
+	go func() {
+		errCh <- pf.ForwardPorts()
+	}()
+
+	go func() {
+		select {
+		case <-ctx.Done():
+			f.close()
+		case <-f.stopCh:
+		}
+	}()
+
+	select {
+	case <-readyCh:
+	case err := <-errCh:
+		f.close()
+		if apierrors.IsForbidden(dialer.err) {
+			return nil, &forbiddenError{namespace: pod.Namespace, verb: "create", resource: "pods/portforward", err: dialer.err}
+		}
+		if err == nil {
+			err = fmt.Errorf("port forward stopped before it was ready")
+		}
+		return nil, &forwardFailedError{pod: pod.Name, err: err}
+	case <-ctx.Done():
+		f.close()
+		return nil, ctx.Err()
+	}
+
+	ports, err := pf.GetPorts()
+	if err != nil {
+		f.close()
+		return nil, &forwardFailedError{pod: pod.Name, err: err}
+	}
+	if len(ports) != 1 {
+		f.close()
+		return nil, &forwardFailedError{pod: pod.Name, err: fmt.Errorf("unexpected number of forwarded ports %d", len(ports))}
+	}
+
+	f.localPort = ports[0].Local
+	logDebug("Port-forwarding to pod %s is ready to handle traffic on local port %d", pod.Name, f.localPort)
+
+	return f, nil
+}
+
+// vaultClient returns a copy of c talking to Vault through the port forward.
+func (k *kubeConn) vaultClient(c *api.Client, f *portForward) (*api.Client, error) {
+	fc, err := c.Clone()
+	if err != nil {
+		return nil, err
+	}
+
+	if err := fc.SetAddress(k.forwardURL(strconv.Itoa(int(f.localPort)))); err != nil {
+		return nil, err
+	}
+	// Clones only keep the token when the client is configured to
+	fc.SetToken(c.Token())
+
+	return fc, nil
+}
+
+// selectPod picks the pod behind the service to talk to according to the
+// configured pod_selection policy. Only running pods are considered.
+func (k *kubeConn) selectPod(ctx context.Context, svc *v1.Service, pods *v1.PodList) (*v1.Pod, error) {
+	for i := range pods.Items {
+		pod := &pods.Items[i]
+		if pod.Status.Phase != v1.PodRunning {
+			continue
+		}
+
+		switch k.podSelection {
+		case podSelectionName:
+			if pod.Name != k.podName {
+				continue
+			}
+		case podSelectionOrdinal:
+			if !isStatefulSetOrdinal(pod, k.podOrdinal) {
+				continue
+			}
+		case podSelectionActive, podSelectionUninitialized:
+			state, err := k.podState(ctx, svc, pod)
+			if err != nil {
+				logInfo("skipping pod %s: %v", pod.Name, err)
+				continue
+			}
+			if k.podSelection == podSelectionActive && !state.active() {
+				continue
+			}
+			if k.podSelection == podSelectionUninitialized && state.Initialized {
+				continue
+			}
+		}
+
+		return pod, nil
+	}
+
+	switch k.podSelection {
+	case podSelectionName:
+		return nil, fmt.Errorf("pod %q is not running behind the service", k.podName)
+	case podSelectionOrdinal:
+		return nil, fmt.Errorf("no running StatefulSet pod with ordinal %d behind the service", k.podOrdinal)
+	case podSelectionActive:
+		return nil, fmt.Errorf("no active Vault pod behind the service")
+	case podSelectionUninitialized:
+		return nil, fmt.Errorf("no uninitialized Vault pod behind the service")
+	}
+
+	return nil, &noRunningPodsError{namespace: svc.Namespace, service: svc.Name, selector: mapToSelectorStr(svc.Spec.Selector)}
+}
+
+// podState returns the Vault state of a pod, from the service registration
+// labels when present, and otherwise by probing the pod's sys/health endpoint
+// through the Kubernetes API server.
+func (k *kubeConn) podState(ctx context.Context, svc *v1.Service, pod *v1.Pod) (podState, error) {
+	initialized, okInitialized := pod.Labels[labelVaultInitialized]
+	sealed, okSealed := pod.Labels[labelVaultSealed]
+	active, okActive := pod.Labels[labelVaultActive]
+
+	if okInitialized && okSealed && okActive {
+		return podState{
+			Initialized: initialized == "true",
+			Sealed:      sealed == "true",
+			Standby:     active != "true",
+		}, nil
+	}
+
+	logDebug("pod %s has no Vault service registration labels, probing sys/health", pod.Name)
+
+	// Make sys/health answer 200 whatever the state, the body tells it all
+	params := map[string]string{
+		"standbyok":     "true",
+		"perfstandbyok": "true",
+		"sealedcode":    "200",
+		"uninitcode":    "200",
+	}
+
+	port, err := targetPort(svc, pod, k.remotePort)
+	if err != nil {
+		return podState{}, err
+	}
+
+	raw, err := k.kubeClient.CoreV1().Pods(pod.Namespace).
+		ProxyGet(k.scheme, pod.Name, strconv.Itoa(int(port)), "v1/sys/health", params).
+		DoRaw(ctx)
+	if err != nil {
+		return podState{}, fmt.Errorf("failed to probe Vault health: %w", err)
+	}
+
+	var state podState
+	if err := json.Unmarshal(raw, &state); err != nil {
+		return podState{}, fmt.Errorf("failed to parse Vault health: %w", err)
+	}
+
+	return state, nil
+}
+
+// isStatefulSetOrdinal reports whether the pod is the StatefulSet replica
+// with the given ordinal.
+func isStatefulSetOrdinal(pod *v1.Pod, ordinal int) bool {
+	for _, owner := range pod.OwnerReferences {
+		if owner.Kind == "StatefulSet" {
+			return pod.Name == owner.Name+"-"+strconv.Itoa(ordinal)
+		}
+	}
+
+	return false
+}
+
+// targetPort resolves a service port, given by number or name, to the
+// container port it targets on the given pod. Named target ports are looked
+// up among the pod's container ports.
+func targetPort(svc *v1.Service, pod *v1.Pod, servicePort string) (int32, error) {
+	var port *v1.ServicePort
+	for i, p := range svc.Spec.Ports {
+		if p.Name == servicePort || strconv.Itoa(int(p.Port)) == servicePort {
+			port = &svc.Spec.Ports[i]
+			break
+		}
+	}
+
+	if port == nil {
+		available := make([]string, 0, len(svc.Spec.Ports))
+		for _, p := range svc.Spec.Ports {
+			available = append(available, describePort(p.Name, p.Port))
+		}
+		return 0, fmt.Errorf("service %s/%s has no port %q, available ports: %s",
+			svc.Namespace, svc.Name, servicePort, strings.Join(available, ", "))
+	}
+
+	switch {
+	case port.TargetPort.Type == intstr.String:
+		var available []string
+		for _, c := range pod.Spec.Containers {
+			for _, p := range c.Ports {
+				if p.Name == port.TargetPort.StrVal {
+					return p.ContainerPort, nil
+				}
+				available = append(available, describePort(p.Name, p.ContainerPort))
+			}
+		}
+		return 0, fmt.Errorf("pod %s has no container port named %q for service port %s, available ports: %s",
+			pod.Name, port.TargetPort.StrVal, describePort(port.Name, port.Port), strings.Join(available, ", "))
+	case port.TargetPort.IntVal != 0:
+		return port.TargetPort.IntVal, nil
+	default:
+		// An unset targetPort defaults to the service port
+		return port.Port, nil
+	}
+}
+
+func describePort(name string, port int32) string {
+	if name == "" {
+		return strconv.Itoa(int(port))
+	}
+	return fmt.Sprintf("%d (%s)", port, name)
+}
+
+func mapToSelectorStr(msel map[string]string) string {
+	selector := ""
+	for k, v := range msel {
+		if selector != "" {
+			selector = selector + ","
+		}
+		selector = selector + fmt.Sprintf("%s=%s", k, v)
+	}
+
+	return selector
+}
@@ -0,0 +1,132 @@
+package provider
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+
+	"github.com/hashicorp/terraform-plugin-sdk/v2/diag"
+)
+
+// serviceNotFoundError is returned when the Vault service does not exist
+type serviceNotFoundError struct {
+	namespace string
+	service   string
+}
+
+func (e *serviceNotFoundError) Error() string {
+	return fmt.Sprintf("service %s/%s not found", e.namespace, e.service)
+}
+
+// emptySelectorError is returned when the Vault service has no pod selector
+type emptySelectorError struct {
+	namespace string
+	service   string
+}
+
+func (e *emptySelectorError) Error() string {
+	return fmt.Sprintf("service %s/%s has no selector", e.namespace, e.service)
+}
+
+// noRunningPodsError is returned when no running pod matches the service
+// selector
+type noRunningPodsError struct {
+	namespace string
+	service   string
+	selector  string
+}
+
+func (e *noRunningPodsError) Error() string {
+	return fmt.Sprintf("no running pods behind service %s/%s", e.namespace, e.service)
+}
+
+// forbiddenError is returned when RBAC denies a Kubernetes operation
+type forbiddenError struct {
+	namespace string
+	verb      string
+	resource  string
+	err       error
+}
+
+func (e *forbiddenError) Error() string {
+	return fmt.Sprintf("not allowed to %s %s in namespace %s: %v", e.verb, e.resource, e.namespace, e.err)
+}
+
+func (e *forbiddenError) Unwrap() error {
+	return e.err
+}
+
+// missingPermissionsError is returned when the RBAC preflight finds
+// permissions the Kubernetes identity lacks
+type missingPermissionsError struct {
+	namespace   string
+	permissions []string
+}
+
+func (e *missingPermissionsError) Error() string {
+	return fmt.Sprintf("missing permissions in namespace %s: %s", e.namespace, strings.Join(e.permissions, ", "))
+}
+
+// forwardFailedError is returned when the port forward to a pod cannot be
+// established
+type forwardFailedError struct {
+	pod string
+	err error
+}
+
+func (e *forwardFailedError) Error() string {
+	return fmt.Sprintf("port forward to pod %s failed: %v", e.pod, e.err)
+}
+
+func (e *forwardFailedError) Unwrap() error {
+	return e.err
+}
+
+// kubeDiagnostics turns an error from a Kubernetes operation into a
+// diagnostic telling the user what to fix.
+func kubeDiagnostics(err error) diag.Diagnostics {
+	var (
+		serviceNotFound *serviceNotFoundError
+		emptySelector   *emptySelectorError
+		noRunningPods   *noRunningPodsError
+		forbidden       *forbiddenError
+		missing         *missingPermissionsError
+		forwardFailed   *forwardFailedError
+	)
+
+	var summary, detail string
+
+	switch {
+	case errors.As(err, &serviceNotFound):
+		summary = "Vault service not found"
+		detail = fmt.Sprintf("Service %q was not found in namespace %q. Check %q and %q in %q.",
+			serviceNotFound.service, serviceNotFound.namespace, argServiceName, argNameSpace, argKubeConfig)
+	case errors.As(err, &emptySelector):
+		summary = "Vault service has no selector"
+		detail = fmt.Sprintf("Service %q in namespace %q has no pod selector, so the Vault pods behind it cannot be found.",
+			emptySelector.service, emptySelector.namespace)
+	case errors.As(err, &noRunningPods):
+		summary = "No running Vault pods"
+		detail = fmt.Sprintf("No running pod matching %q was found behind service %q in namespace %q.",
+			noRunningPods.selector, noRunningPods.service, noRunningPods.namespace)
+	case errors.As(err, &forbidden):
+		summary = "Kubernetes permission denied"
+		detail = fmt.Sprintf("The Kubernetes identity is not allowed to %s %q in namespace %q: %v",
+			forbidden.verb, forbidden.resource, forbidden.namespace, forbidden.err)
+	case errors.As(err, &missing):
+		summary = "Missing Kubernetes permissions"
+		detail = fmt.Sprintf("The Kubernetes identity lacks the following permissions in namespace %q: %s.",
+			missing.namespace, strings.Join(missing.permissions, ", "))
+	case errors.As(err, &forwardFailed):
+		summary = "Port forward to Vault failed"
+		detail = fmt.Sprintf("Port forwarding to pod %q failed: %v", forwardFailed.pod, forwardFailed.err)
+	default:
+		return diag.FromErr(err)
+	}
+
+	return diag.Diagnostics{{
+		Severity: diag.Error,
+		Summary:  summary,
+		Detail:   detail,
+	}}
+}
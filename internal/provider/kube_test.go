@@ -0,0 +1,397 @@
+package provider
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	authorizationv1 "k8s.io/api/authorization/v1"
+	v1 "k8s.io/api/core/v1"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/util/intstr"
+	"k8s.io/client-go/kubernetes/fake"
+	restclient "k8s.io/client-go/rest"
+	k8stesting "k8s.io/client-go/testing"
+)
+
+// rawResponse is a restclient.ResponseWrapper returning a fixed body
+type rawResponse string
+
+func (r rawResponse) DoRaw(context.Context) ([]byte, error) {
+	return []byte(r), nil
+}
+
+func (r rawResponse) Stream(context.Context) (io.ReadCloser, error) {
+	return io.NopCloser(strings.NewReader(string(r))), nil
+}
+
+func testPod(name string, phase v1.PodPhase, labels map[string]string) v1.Pod {
+	return v1.Pod{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:      name,
+			Namespace: "vault",
+			Labels:    labels,
+			OwnerReferences: []metav1.OwnerReference{
+				{Kind: "StatefulSet", Name: "vault"},
+			},
+		},
+		Status: v1.PodStatus{Phase: phase},
+	}
+}
+
+func testService(ports ...v1.ServicePort) *v1.Service {
+	return &v1.Service{
+		ObjectMeta: metav1.ObjectMeta{Name: "vault", Namespace: "vault"},
+		Spec: v1.ServiceSpec{
+			Selector: map[string]string{"app.kubernetes.io/name": "vault"},
+			Ports:    ports,
+		},
+	}
+}
+
+func vaultLabels(initialized, sealed, active bool) map[string]string {
+	return map[string]string{
+		labelVaultInitialized: fmt.Sprint(initialized),
+		labelVaultSealed:      fmt.Sprint(sealed),
+		labelVaultActive:      fmt.Sprint(active),
+	}
+}
+
+func TestKubeConn_selectPod(t *testing.T) {
+	pods := &v1.PodList{Items: []v1.Pod{
+		testPod("vault-0", v1.PodPending, vaultLabels(false, true, false)),
+		testPod("vault-1", v1.PodRunning, vaultLabels(true, false, false)),
+		testPod("vault-2", v1.PodRunning, vaultLabels(true, false, true)),
+		testPod("vault-3", v1.PodRunning, vaultLabels(false, true, false)),
+	}}
+
+	cases := []struct {
+		name    string
+		conn    kubeConn
+		want    string
+		wantErr bool
+	}{
+		{name: "any", conn: kubeConn{podSelection: podSelectionAny}, want: "vault-1"},
+		{name: "active", conn: kubeConn{podSelection: podSelectionActive}, want: "vault-2"},
+		{name: "uninitialized", conn: kubeConn{podSelection: podSelectionUninitialized}, want: "vault-3"},
+		{name: "name", conn: kubeConn{podSelection: podSelectionName, podName: "vault-2"}, want: "vault-2"},
+		{name: "name not running", conn: kubeConn{podSelection: podSelectionName, podName: "vault-0"}, wantErr: true},
+		{name: "ordinal", conn: kubeConn{podSelection: podSelectionOrdinal, podOrdinal: 3}, want: "vault-3"},
+		{name: "ordinal missing", conn: kubeConn{podSelection: podSelectionOrdinal, podOrdinal: 7}, wantErr: true},
+	}
+
+	for _, c := range cases {
+		t.Run(c.name, func(t *testing.T) {
+			got, err := c.conn.selectPod(context.TODO(), testService(), pods)
+			if c.wantErr {
+				if err == nil {
+					t.Fatalf("expected an error, got pod %q", got.Name)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatal(err)
+			}
+			if got.Name != c.want {
+				t.Fatalf("expected pod %q, got %q", c.want, got.Name)
+			}
+		})
+	}
+}
+
+func TestKubeConn_selectPod_healthProbe(t *testing.T) {
+	client := fake.NewSimpleClientset()
+	client.PrependProxyReactor("pods", func(action k8stesting.Action) (bool, restclient.ResponseWrapper, error) {
+		proxy := action.(k8stesting.ProxyGetAction)
+		if proxy.GetPath() != "v1/sys/health" {
+			t.Errorf("unexpected path %q", proxy.GetPath())
+		}
+		if proxy.GetPort() != "8200" {
+			t.Errorf("unexpected port %q", proxy.GetPort())
+		}
+
+		switch proxy.GetName() {
+		case "vault-0":
+			return true, rawResponse(`{"initialized":true,"sealed":false,"standby":true}`), nil
+		case "vault-1":
+			return true, rawResponse(`{"initialized":true,"sealed":false,"standby":false}`), nil
+		}
+		return true, nil, fmt.Errorf("unexpected pod %q", proxy.GetName())
+	})
+
+	k := &kubeConn{
+		scheme:       "http",
+		remotePort:   "8200",
+		podSelection: podSelectionActive,
+		kubeClient:   client,
+	}
+
+	pods := &v1.PodList{Items: []v1.Pod{
+		testPod("vault-0", v1.PodRunning, nil),
+		testPod("vault-1", v1.PodRunning, nil),
+	}}
+
+	svc := testService(v1.ServicePort{Name: "http", Port: 8200})
+
+	got, err := k.selectPod(context.TODO(), svc, pods)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got.Name != "vault-1" {
+		t.Fatalf("expected pod %q, got %q", "vault-1", got.Name)
+	}
+}
+
+func TestTargetPort(t *testing.T) {
+	svc := testService(
+		v1.ServicePort{Name: "http", Port: 8200, TargetPort: intstr.FromString("https-internal")},
+		v1.ServicePort{Name: "https-internal", Port: 8201, TargetPort: intstr.FromInt(9201)},
+		v1.ServicePort{Name: "metrics", Port: 9102},
+		v1.ServicePort{Name: "missing", Port: 9000, TargetPort: intstr.FromString("missing")},
+	)
+
+	pod := testPod("vault-0", v1.PodRunning, nil)
+	pod.Spec.Containers = []v1.Container{{
+		Name: "vault",
+		Ports: []v1.ContainerPort{
+			{Name: "https-internal", ContainerPort: 9200},
+			{Name: "metrics", ContainerPort: 9102},
+		},
+	}}
+
+	cases := []struct {
+		port    string
+		want    int32
+		wantErr string
+	}{
+		{port: "8200", want: 9200},
+		{port: "http", want: 9200},
+		{port: "8201", want: 9201},
+		{port: "9102", want: 9102},
+		{port: "missing", wantErr: "available ports: 9200 (https-internal), 9102 (metrics)"},
+		{port: "8300", wantErr: "available ports: 8200 (http), 8201 (https-internal), 9102 (metrics), 9000 (missing)"},
+	}
+
+	for _, c := range cases {
+		t.Run(c.port, func(t *testing.T) {
+			got, err := targetPort(svc, &pod, c.port)
+			if c.wantErr != "" {
+				if err == nil || !strings.Contains(err.Error(), c.wantErr) {
+					t.Fatalf("expected an error containing %q, got %v", c.wantErr, err)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatal(err)
+			}
+			if got != c.want {
+				t.Fatalf("expected port %d, got %d", c.want, got)
+			}
+		})
+	}
+}
+
+func TestKubeConn_vaultPods(t *testing.T) {
+	forbidden := func(resource string) k8stesting.ReactionFunc {
+		return func(action k8stesting.Action) (bool, runtime.Object, error) {
+			return true, nil, apierrors.NewForbidden(v1.Resource(resource), "", fmt.Errorf("RBAC denied"))
+		}
+	}
+
+	selectorless := testService()
+	selectorless.Spec.Selector = nil
+
+	running := testPod("vault-0", v1.PodRunning, nil)
+	running.Labels = testService().Spec.Selector
+
+	cases := []struct {
+		name    string
+		objects []runtime.Object
+		reactor func(*fake.Clientset)
+		check   func(error) bool
+	}{
+		{
+			name:  "service not found",
+			check: func(err error) bool { var e *serviceNotFoundError; return errors.As(err, &e) },
+		},
+		{
+			name:    "empty selector",
+			objects: []runtime.Object{selectorless},
+			check:   func(err error) bool { var e *emptySelectorError; return errors.As(err, &e) },
+		},
+		{
+			name:    "no pods",
+			objects: []runtime.Object{testService()},
+			check:   func(err error) bool { var e *noRunningPodsError; return errors.As(err, &e) },
+		},
+		{
+			name: "service forbidden",
+			reactor: func(c *fake.Clientset) {
+				c.PrependReactor("get", "services", forbidden("services"))
+			},
+			check: func(err error) bool {
+				var e *forbiddenError
+				return errors.As(err, &e) && e.verb == "get" && e.resource == "services"
+			},
+		},
+		{
+			name:    "pods forbidden",
+			objects: []runtime.Object{testService()},
+			reactor: func(c *fake.Clientset) {
+				c.PrependReactor("list", "pods", forbidden("pods"))
+			},
+			check: func(err error) bool {
+				var e *forbiddenError
+				return errors.As(err, &e) && e.verb == "list" && e.resource == "pods"
+			},
+		},
+		{
+			name:    "found",
+			objects: []runtime.Object{testService(), &running},
+			check:   func(err error) bool { return err == nil },
+		},
+	}
+
+	for _, c := range cases {
+		t.Run(c.name, func(t *testing.T) {
+			client := fake.NewSimpleClientset(c.objects...)
+			if c.reactor != nil {
+				c.reactor(client)
+			}
+
+			k := &kubeConn{nameSpace: "vault", serviceName: "vault", kubeClient: client}
+
+			_, _, err := k.vaultPods(context.TODO())
+			if !c.check(err) {
+				t.Fatalf("unexpected error: %v", err)
+			}
+		})
+	}
+}
+
+func TestKubeConn_forward(t *testing.T) {
+	cases := []struct {
+		name   string
+		status int
+		body   string
+		check  func(error) bool
+	}{
+		{
+			name:   "forbidden",
+			status: http.StatusForbidden,
+			body:   `{"kind":"Status","apiVersion":"v1","status":"Failure","message":"pods \"vault-0\" is forbidden","reason":"Forbidden","code":403}`,
+			check: func(err error) bool {
+				var e *forbiddenError
+				return errors.As(err, &e) && e.verb == "create" && e.resource == "pods/portforward"
+			},
+		},
+		{
+			name:   "upgrade failed",
+			status: http.StatusBadGateway,
+			body:   "no upstream",
+			check:  func(err error) bool { var e *forwardFailedError; return errors.As(err, &e) && e.pod == "vault-0" },
+		},
+	}
+
+	for _, c := range cases {
+		t.Run(c.name, func(t *testing.T) {
+			server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				if r.URL.Path != "/api/v1/namespaces/vault/pods/vault-0/portforward" {
+					t.Errorf("unexpected path %q", r.URL.Path)
+				}
+				w.Header().Set("Content-Type", "application/json")
+				w.WriteHeader(c.status)
+				io.WriteString(w, c.body)
+			}))
+			defer server.Close()
+
+			k := &kubeConn{
+				remotePort: "8200",
+				kubeConfig: &restclient.Config{Host: server.URL},
+			}
+
+			pod := testPod("vault-0", v1.PodRunning, nil)
+			svc := testService(v1.ServicePort{Name: "http", Port: 8200})
+
+			f, err := k.forward(context.TODO(), svc, &pod, "0")
+			if err == nil {
+				f.close()
+				t.Fatal("expected an error")
+			}
+			if !c.check(err) {
+				t.Fatalf("unexpected error: %v", err)
+			}
+		})
+	}
+}
+
+func TestKubeDiagnostics(t *testing.T) {
+	cases := []struct {
+		err     error
+		summary string
+	}{
+		{&serviceNotFoundError{namespace: "vault", service: "vault"}, "Vault service not found"},
+		{&emptySelectorError{namespace: "vault", service: "vault"}, "Vault service has no selector"},
+		{&noRunningPodsError{namespace: "vault", service: "vault"}, "No running Vault pods"},
+		{&forbiddenError{namespace: "vault", verb: "get", resource: "services", err: errors.New("denied")}, "Kubernetes permission denied"},
+		{&missingPermissionsError{namespace: "vault", permissions: []string{"get services"}}, "Missing Kubernetes permissions"},
+		{&forwardFailedError{pod: "vault-0", err: errors.New("lost")}, "Port forward to Vault failed"},
+		{errors.New("other"), "other"},
+	}
+
+	for _, c := range cases {
+		diags := kubeDiagnostics(c.err)
+		if len(diags) != 1 || diags[0].Summary != c.summary {
+			t.Errorf("expected summary %q for %v, got %v", c.summary, c.err, diags)
+		}
+	}
+}
+
+func TestKubeConn_checkPermissions(t *testing.T) {
+	client := fake.NewSimpleClientset()
+	client.PrependReactor("create", "selfsubjectaccessreviews", func(action k8stesting.Action) (bool, runtime.Object, error) {
+		review := action.(k8stesting.CreateAction).GetObject().(*authorizationv1.SelfSubjectAccessReview)
+		attributes := review.Spec.ResourceAttributes
+		if attributes.Namespace != "vault" {
+			t.Errorf("unexpected namespace %q", attributes.Namespace)
+		}
+
+		// Only reading services and listing pods is allowed
+		review.Status.Allowed = (attributes.Verb == "get" && attributes.Resource == "services") ||
+			(attributes.Verb == "list" && attributes.Resource == "pods")
+		return true, review, nil
+	})
+
+	k := &kubeConn{nameSpace: "vault", caSecret: "vault-tls", podSelection: podSelectionActive, kubeClient: client}
+
+	err := k.checkPermissions(context.TODO())
+
+	var missing *missingPermissionsError
+	if !errors.As(err, &missing) {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	// The active pod is probed through pods/proxy without the labels
+	want := []string{"create pods/portforward", "get pods/proxy", "get secrets"}
+	if strings.Join(missing.permissions, ",") != strings.Join(want, ",") {
+		t.Fatalf("expected missing permissions %v, got %v", want, missing.permissions)
+	}
+
+	k.caSecret = ""
+	client.PrependReactor("create", "selfsubjectaccessreviews", func(action k8stesting.Action) (bool, runtime.Object, error) {
+		review := action.(k8stesting.CreateAction).GetObject().(*authorizationv1.SelfSubjectAccessReview)
+		review.Status.Allowed = true
+		return true, review, nil
+	})
+
+	if err := k.checkPermissions(context.TODO()); err != nil {
+		t.Fatal(err)
+	}
+}
@@ -2,17 +2,24 @@ package provider
 
 import (
 	"context"
+	"errors"
 	"fmt"
 	"github.com/hashicorp/terraform-plugin-sdk/v2/diag"
 	"github.com/hashicorp/terraform-plugin-sdk/v2/helper/schema"
+	"github.com/hashicorp/terraform-plugin-sdk/v2/helper/validation"
 	"github.com/hashicorp/vault/api"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	k8sschema "k8s.io/apimachinery/pkg/runtime/schema"
 	"k8s.io/client-go/kubernetes"
 	restclient "k8s.io/client-go/rest"
 	"k8s.io/client-go/tools/clientcmd"
 	clientcmdapi "k8s.io/client-go/tools/clientcmd/api"
 	"log"
+	"net/http"
+	"net/url"
 	"os"
 	"strings"
+	"sync"
 )
 
 const (
@@ -20,9 +27,23 @@ const (
 	envVaultSkipVerify = "VAULT_SKIP_VERIFY"
 	provider           = "vaultoperator"
 	resInit            = provider + "_init"
+	resRaftJoin        = provider + "_raft_join"
+	resRaftPeers       = provider + "_raft_peers"
+	resRaftSnapshot    = provider + "_raft_snapshot"
+	resRaftRestore     = provider + "_raft_snapshot_restore"
+	dsRaftConfig       = provider + "_raft_configuration"
+	resRaftAutopilot   = provider + "_raft_autopilot"
+	dsAutopilotState   = provider + "_raft_autopilot_state"
+	resKeyRotation     = provider + "_key_rotation"
+	dsKeyStatus        = provider + "_key_status"
+	resStepDown        = provider + "_step_down"
+	dsLeader           = provider + "_leader"
+	dsHAStatus         = provider + "_ha_status"
+	dsHealth           = provider + "_health"
 	argVaultUrl        = "vault_url"
 	argVaultAddr       = "vault_addr"
 	argVaultSkipVerify = "vault_skip_verify"
+	argVaultToken      = "vault_token"
 	argRequestHeaders  = "request_headers"
 	argKubeConfig      = "kube_config"
 	argKubeConfigPath  = "path"
@@ -30,6 +51,46 @@ const (
 	argServiceName     = "service"
 	argLocalPort       = "local_port"
 	argRemotePort      = "remote_port"
+	argScheme          = "scheme"
+	argTLSServerName   = "tls_server_name"
+	argCASecret        = "ca_secret"
+	argCASecretKey     = "ca_secret_key"
+	argPodSelection    = "pod_selection"
+	argPodName         = "pod_name"
+	argPodOrdinal      = "pod_ordinal"
+	argTransport       = "transport"
+
+	argKubeHost                 = "host"
+	argKubeToken                = "token"
+	argKubeClusterCACertificate = "cluster_ca_certificate"
+	argKubeClientCertificate    = "client_certificate"
+	argKubeClientKey            = "client_key"
+	argKubeConfigContext        = "config_context"
+	argKubeConfigContextCluster = "config_context_cluster"
+	argKubeConfigContextAuth    = "config_context_auth_info"
+	argInCluster                = "in_cluster"
+	argExec                     = "exec"
+	argExecInteractiveMode      = "interactive_mode"
+	argExecInstallHint          = "install_hint"
+	argExecProvideClusterInfo   = "provide_cluster_info"
+
+	defaultKubeConfigPath    = "~/.kube/config"
+	envKubernetesServiceHost = "KUBERNETES_SERVICE_HOST"
+)
+
+var (
+	execInteractiveModes = []string{
+		string(clientcmdapi.NeverExecInteractiveMode),
+		string(clientcmdapi.IfAvailableExecInteractiveMode),
+		string(clientcmdapi.AlwaysExecInteractiveMode),
+	}
+
+	// inClusterConfig builds the Kubernetes config from the service account
+	// mounted into the pod the provider runs in
+	inClusterConfig = restclient.InClusterConfig
+	// serviceAccountNamespaceFile holds the namespace of the pod the provider
+	// runs in
+	serviceAccountNamespaceFile = "/var/run/secrets/kubernetes.io/serviceaccount/namespace"
 )
 
 func init() {
@@ -53,10 +114,23 @@ func New(version string) func() *schema.Provider {
 		p := &schema.Provider{
 			Schema: providerSchema(),
 			ResourcesMap: map[string]*schema.Resource{
-				resInit: resourceInit(),
+				resInit:          resourceInit(),
+				resRaftJoin:      resourceRaftJoin(),
+				resRaftPeers:     resourceRaftPeers(),
+				resRaftSnapshot:  resourceRaftSnapshot(),
+				resRaftRestore:   resourceRaftSnapshotRestore(),
+				resRaftAutopilot: resourceRaftAutopilot(),
+				resKeyRotation:   resourceKeyRotation(),
+				resStepDown:      resourceStepDown(),
 			},
 			DataSourcesMap: map[string]*schema.Resource{
-				resInit: providerDatasource(),
+				resInit:          providerDatasource(),
+				dsRaftConfig:     dataSourceRaftConfiguration(),
+				dsAutopilotState: dataSourceRaftAutopilotState(),
+				dsKeyStatus:      dataSourceKeyStatus(),
+				dsLeader:         dataSourceLeader(),
+				dsHAStatus:       dataSourceHAStatus(),
+				dsHealth:         dataSourceHealth(),
 			},
 		}
 
@@ -67,13 +141,21 @@ func New(version string) func() *schema.Provider {
 }
 
 type kubeConn struct {
-	configPath  string
-	nameSpace   string
-	serviceName string
-	localPort   string
-	remotePort  string
-	kubeConfig  *restclient.Config
-	kubeClient  *kubernetes.Clientset
+	configPath    string
+	nameSpace     string
+	serviceName   string
+	localPort     string
+	remotePort    string
+	scheme        string
+	podSelection  string
+	podName       string
+	podOrdinal    int
+	transport     string
+	caSecret      string
+	caSecretKey   string
+	tlsServerName string
+	kubeConfig    *restclient.Config
+	kubeClient    kubernetes.Interface
 }
 
 type apiClient struct {
@@ -83,6 +165,18 @@ type apiClient struct {
 	client   *api.Client
 	url      string
 	kubeConn kubeConn
+
+	// What the Vault API client is built from, kept to build the clients
+	// of per-resource connections
+	tlsConfig api.TLSConfig
+	tunnel    *sshTunnel
+	proxyURL  *url.URL
+	token     string
+
+	// The provider configuration, read on first use
+	providerData   *schema.ResourceData
+	configureOnce  sync.Once
+	configureDiags diag.Diagnostics
 }
 
 func providerSchema() map[string]*schema.Schema {
@@ -104,6 +198,12 @@ func providerSchema() map[string]*schema.Schema {
 			DefaultFunc: schema.EnvDefaultFunc(envVaultSkipVerify, false),
 			Description: "Disable TLS certificate verification",
 		},
+		argVaultToken: {
+			Type:        schema.TypeString,
+			Optional:    true,
+			Sensitive:   true,
+			Description: "Vault token for the operations which need one, like reading the raft configuration. Defaults to `VAULT_TOKEN`. It can come from the `root_token` of `vaultoperator_init`",
+		},
 		argRequestHeaders: {
 			Type:     schema.TypeMap,
 			Optional: true,
@@ -119,13 +219,59 @@ func providerSchema() map[string]*schema.Schema {
 					argKubeConfigPath: {
 						Type:        schema.TypeString,
 						Optional:    true,
-						Description: "Full path to a Kubernetes config",
-						Default:     "~/.kube/config",
+						Description: "Full path to a Kubernetes config. Defaults to `~/.kube/config` unless `host` or `in_cluster` is set",
+					},
+					argInCluster: {
+						Type:        schema.TypeBool,
+						Optional:    true,
+						Description: "Use the service account of the pod Terraform runs in. This is also the fallback when neither `path` nor `host` is set and `~/.kube/config` does not exist inside a Kubernetes pod. `namespace` then defaults to the namespace of the pod",
+					},
+					argKubeHost: {
+						Type:        schema.TypeString,
+						Optional:    true,
+						Description: "The hostname (in form of URI) of the Kubernetes API",
+					},
+					argKubeToken: {
+						Type:        schema.TypeString,
+						Optional:    true,
+						Sensitive:   true,
+						Description: "Token to authenticate to the Kubernetes API",
+					},
+					argKubeClusterCACertificate: {
+						Type:        schema.TypeString,
+						Optional:    true,
+						Description: "PEM-encoded root certificates bundle for TLS authentication of the Kubernetes API",
+					},
+					argKubeClientCertificate: {
+						Type:        schema.TypeString,
+						Optional:    true,
+						Description: "PEM-encoded client certificate for TLS authentication to the Kubernetes API",
+					},
+					argKubeClientKey: {
+						Type:        schema.TypeString,
+						Optional:    true,
+						Sensitive:   true,
+						Description: "PEM-encoded client certificate key for TLS authentication to the Kubernetes API",
+					},
+					argKubeConfigContext: {
+						Type:        schema.TypeString,
+						Optional:    true,
+						Description: "Context to use from the Kubernetes config, instead of its current context",
+					},
+					argKubeConfigContextCluster: {
+						Type:        schema.TypeString,
+						Optional:    true,
+						Description: "Cluster to use from the Kubernetes config, overriding the one of the context",
+					},
+					argKubeConfigContextAuth: {
+						Type:        schema.TypeString,
+						Optional:    true,
+						Description: "User to use from the Kubernetes config, overriding the one of the context",
 					},
 					argNameSpace: {
 						Type:        schema.TypeString,
 						Optional:    true,
-						Description: "Kubernetes namespace where HC Vault is run",
+						Description: "Kubernetes namespace where HC Vault is run. Defaults to the namespace of the pod Terraform runs in, with the in-cluster config",
 					},
 					argServiceName: {
 						Type:        schema.TypeString,
@@ -135,16 +281,64 @@ func providerSchema() map[string]*schema.Schema {
 					argLocalPort: {
 						Type:        schema.TypeString,
 						Optional:    true,
-						Description: "Local forward port",
-						Default:     "8200",
+						Description: "Local forward port. `0` lets the OS pick a free port",
+						Default:     "0",
 					},
 					argRemotePort: {
 						Type:        schema.TypeString,
 						Optional:    true,
-						Description: "Remote service port to forward",
+						Description: "Remote service port to forward, by number or name. It is forwarded to the container port the service targets on the selected pod",
 						Default:     "8200",
 					},
-					"exec": {
+					argScheme: {
+						Type:         schema.TypeString,
+						Optional:     true,
+						Description:  "Scheme Vault serves its API with, `http` or `https`",
+						Default:      "http",
+						ValidateFunc: validation.StringInSlice([]string{"http", "https"}, false),
+					},
+					argTLSServerName: {
+						Type:        schema.TypeString,
+						Optional:    true,
+						Description: "Server name used to verify the Vault certificate when `scheme` is `https`. Defaults to `<service>.<namespace>.svc`",
+					},
+					argCASecret: {
+						Type:        schema.TypeString,
+						Optional:    true,
+						Description: "Name of a Kubernetes Secret in `namespace` holding the CA certificate used to verify Vault when `scheme` is `https`",
+					},
+					argCASecretKey: {
+						Type:        schema.TypeString,
+						Optional:    true,
+						Description: "Key of the CA certificate in `ca_secret`",
+						Default:     "ca.crt",
+					},
+					argPodSelection: {
+						Type:         schema.TypeString,
+						Optional:     true,
+						Description:  "How to pick the Vault pod to forward to: `any` running pod, the `active` node, an `uninitialized` node, the pod named by `pod_name`, or the StatefulSet replica numbered `pod_ordinal`. `active` and `uninitialized` use the labels maintained by Vault's Kubernetes service registration, and probe `sys/health` when they are missing",
+						Default:      podSelectionAny,
+						ValidateFunc: validation.StringInSlice(podSelections, false),
+					},
+					argPodName: {
+						Type:        schema.TypeString,
+						Optional:    true,
+						Description: "Name of the pod to forward to when `pod_selection` is `name`",
+					},
+					argPodOrdinal: {
+						Type:         schema.TypeInt,
+						Optional:     true,
+						Description:  "StatefulSet ordinal of the pod to forward to when `pod_selection` is `ordinal`",
+						ValidateFunc: validation.IntAtLeast(0),
+					},
+					argTransport: {
+						Type:         schema.TypeString,
+						Optional:     true,
+						Description:  "How to reach Vault: `port_forward` to a pod, or `service_proxy` to send the requests to the service through the Kubernetes API server. `service_proxy` does not need `pods/portforward`, but always uses the service to pick the pod, so `pod_selection` and `cluster_wide` are not supported with it",
+						Default:      transportPortForward,
+						ValidateFunc: validation.StringInSlice(transports, false),
+					},
+					argExec: {
 						Type:     schema.TypeList,
 						Optional: true,
 						MaxItems: 1,
@@ -168,9 +362,126 @@ func providerSchema() map[string]*schema.Schema {
 									Optional: true,
 									Elem:     &schema.Schema{Type: schema.TypeString},
 								},
+								argExecInteractiveMode: {
+									Type:         schema.TypeString,
+									Optional:     true,
+									Description:  "The exec plugin's relationship with standard input: `Never`, `IfAvailable` or `Always`",
+									Default:      string(clientcmdapi.NeverExecInteractiveMode),
+									ValidateFunc: validation.StringInSlice(execInteractiveModes, false),
+								},
+								argExecInstallHint: {
+									Type:        schema.TypeString,
+									Optional:    true,
+									Description: "Help text shown when the exec plugin is not installed",
+								},
+								argExecProvideClusterInfo: {
+									Type:        schema.TypeBool,
+									Optional:    true,
+									Description: "Pass the cluster information to the exec plugin in the `KUBERNETES_EXEC_INFO` environment variable",
+								},
+							},
+						},
+						Description: "Exec credential plugin used to authenticate to the Kubernetes API",
+					},
+				},
+			},
+		},
+		argProxy: {
+			Type:          schema.TypeList,
+			Optional:      true,
+			MaxItems:      1,
+			ConflictsWith: []string{argKubeConfig, argSSHTunnel},
+			Description:   "Reach `vault_addr` through a SOCKS5 or HTTP proxy. Without it, the proxy is read from `ALL_PROXY`, honouring `NO_PROXY`, and loopback addresses are never proxied",
+			Elem: &schema.Resource{
+				Schema: map[string]*schema.Schema{
+					argProxyURL: {
+						Type:        schema.TypeString,
+						Required:    true,
+						Description: "URL of the proxy. `socks5://` and `socks5h://` dial through a SOCKS5 proxy, `http://` and `https://` tunnel through an HTTP proxy with CONNECT",
+					},
+					argProxyUsername: {
+						Type:        schema.TypeString,
+						Optional:    true,
+						Description: "User to authenticate to the proxy as",
+					},
+					argProxyPassword: {
+						Type:        schema.TypeString,
+						Optional:    true,
+						Sensitive:   true,
+						Description: "Password to authenticate to the proxy with",
+					},
+				},
+			},
+		},
+		argSSHTunnel: {
+			Type:          schema.TypeList,
+			Optional:      true,
+			MaxItems:      1,
+			ConflictsWith: []string{argKubeConfig},
+			Description:   "Reach `vault_addr` through an SSH bastion, instead of directly",
+			Elem: &schema.Resource{
+				Schema: map[string]*schema.Schema{
+					argSSHHost: {
+						Type:        schema.TypeString,
+						Required:    true,
+						Description: "Address of the SSH server Vault is dialed from, as `host` or `host:port`. The port defaults to `22`",
+					},
+					argSSHUser: {
+						Type:        schema.TypeString,
+						Required:    true,
+						Description: "User to authenticate to the SSH servers as",
+					},
+					argSSHPrivateKey: {
+						Type:        schema.TypeString,
+						Optional:    true,
+						Sensitive:   true,
+						Description: "PEM-encoded private key to authenticate to the SSH servers with",
+					},
+					argSSHPrivateKeyPassphrase: {
+						Type:        schema.TypeString,
+						Optional:    true,
+						Sensitive:   true,
+						Description: "Passphrase of `private_key`",
+					},
+					argSSHAgent: {
+						Type:        schema.TypeBool,
+						Optional:    true,
+						Description: "Authenticate with the keys of the SSH agent listening on `SSH_AUTH_SOCK`",
+					},
+					argSSHKnownHosts: {
+						Type:        schema.TypeString,
+						Optional:    true,
+						Default:     defaultSSHKnownHosts,
+						Description: "Path of the known_hosts file the host keys of the SSH servers are verified with",
+					},
+					argSSHHostKey: {
+						Type:        schema.TypeString,
+						Optional:    true,
+						Description: "Public key of the SSH server, in authorized_keys format, to verify it with instead of `known_hosts`",
+					},
+					argSSHJumpHost: {
+						Type:        schema.TypeList,
+						Optional:    true,
+						Description: "SSH servers to hop through, in order, before reaching `host`",
+						Elem: &schema.Resource{
+							Schema: map[string]*schema.Schema{
+								argSSHHost: {
+									Type:        schema.TypeString,
+									Required:    true,
+									Description: "Address of the SSH server, as `host` or `host:port`. The port defaults to `22`",
+								},
+								argSSHUser: {
+									Type:        schema.TypeString,
+									Optional:    true,
+									Description: "User to authenticate to the SSH server as. Defaults to the `user` of the tunnel",
+								},
+								argSSHHostKey: {
+									Type:        schema.TypeString,
+									Optional:    true,
+									Description: "Public key of the SSH server, in authorized_keys format, to verify it with instead of `known_hosts`",
+								},
 							},
 						},
-						Description: "",
 					},
 				},
 			},
@@ -178,121 +489,389 @@ func providerSchema() map[string]*schema.Schema {
 	}
 }
 
+// configure defers the provider configuration to the first use of the
+// client, since the Vault address or the Kubernetes credentials may come
+// from resources which are only created during apply.
 func configure(version string, p *schema.Provider) func(context.Context, *schema.ResourceData) (interface{}, diag.Diagnostics) {
 	return func(ctx context.Context, d *schema.ResourceData) (interface{}, diag.Diagnostics) {
-		a := &apiClient{}
-		loader := &clientcmd.ClientConfigLoadingRules{}
-		overrides := &clientcmd.ConfigOverrides{}
+		return &apiClient{providerData: d}, nil
+	}
+}
 
-		if k := d.Get(argKubeConfig).([]interface{}); len(k) > 0 {
-			kubeConn := k[0].(map[string]interface{})
+// configure validates the provider configuration and builds the clients on
+// first use. Clients scoped to a vault_connection are built complete.
+func (a *apiClient) configure(ctx context.Context) diag.Diagnostics {
+	if a.providerData == nil {
+		return nil
+	}
 
-			path := kubeConn[argKubeConfigPath].(string)
+	a.configureOnce.Do(func() {
+		a.configureDiags = a.configureProvider(ctx, a.providerData)
+	})
 
-			if strings.Contains(path, "~") {
-				homeDir, err := homeDir()
-				if err != nil {
-					return nil, diag.FromErr(err)
-				}
-				path = strings.Replace(path, "~", homeDir, -1)
-			}
+	return a.configureDiags
+}
 
-			loader.ExplicitPath = path
+func (a *apiClient) configureProvider(ctx context.Context, d *schema.ResourceData) diag.Diagnostics {
+	loader := &clientcmd.ClientConfigLoadingRules{}
+	overrides := &clientcmd.ConfigOverrides{}
 
-			if namespace := kubeConn[argNameSpace].(string); namespace != "" {
-				a.kubeConn.nameSpace = namespace
-			} else {
-				return nil, diag.Errorf("Vault namespace is not specified")
-			}
+	if k := d.Get(argKubeConfig).([]interface{}); len(k) > 0 {
+		kubeConn := k[0].(map[string]interface{})
 
-			if service := kubeConn[argServiceName].(string); service != "" {
-				a.kubeConn.serviceName = service
-			} else {
-				return nil, diag.Errorf("Vault service name is not specified")
-			}
+		path := kubeConn[argKubeConfigPath].(string)
+		host := kubeConn[argKubeHost].(string)
+		inCluster := kubeConn[argInCluster].(bool)
 
-			a.kubeConn.localPort = kubeConn[argLocalPort].(string)
-			a.kubeConn.remotePort = kubeConn[argRemotePort].(string)
-
-			if v, ok := d.GetOk("exec"); ok {
-				exec := &clientcmdapi.ExecConfig{}
-				if spec, ok := v.([]interface{})[0].(map[string]interface{}); ok {
-					exec.APIVersion = spec["api_version"].(string)
-					exec.Command = spec["command"].(string)
-					exec.Args = expandStringSlice(spec["args"].([]interface{}))
-					for kk, vv := range spec["env"].(map[string]interface{}) {
-						exec.Env = append(exec.Env, clientcmdapi.ExecEnvVar{Name: kk, Value: vv.(string)})
-					}
-				} else {
-					return nil, diag.Errorf("Failed to parse exec")
-				}
-				overrides.AuthInfo.Exec = exec
-			}
+		if inCluster && (path != "" || host != "") {
+			return diag.Errorf("%q cannot be combined with %q or %q", argInCluster, argKubeConfigPath, argKubeHost)
+		}
 
-			cc := clientcmd.NewNonInteractiveDeferredLoadingClientConfig(loader, overrides)
-			cfg, err := cc.ClientConfig()
+		if path == "" && host == "" && !inCluster {
+			path = defaultKubeConfigPath
+		}
+
+		if strings.Contains(path, "~") {
+			homeDir, err := homeDir()
 			if err != nil {
-				log.Printf("[WARN] Invalid provider configuration was supplied. Provider operations likely to fail: %v", err)
-				return nil, nil
+				return diag.FromErr(err)
 			}
+			path = strings.Replace(path, "~", homeDir, -1)
+		}
+
+		// Without an explicit Kubernetes config, fall back to the service
+		// account of the pod when running inside a cluster
+		if kubeConn[argKubeConfigPath].(string) == "" && host == "" && !inCluster {
+			if _, err := os.Stat(path); os.IsNotExist(err) && os.Getenv(envKubernetesServiceHost) != "" {
+				logInfo("%s does not exist, using the in-cluster Kubernetes config", path)
+				inCluster = true
+			}
+		}
 
-			cfg.QPS = 100.0
-			cfg.Burst = 100
-			a.kubeConn.kubeConfig = cfg
+		loader.ExplicitPath = path
 
-			a.kubeConn.kubeClient, err = kubernetes.NewForConfig(cfg)
+		if namespace := kubeConn[argNameSpace].(string); namespace != "" {
+			a.kubeConn.nameSpace = namespace
+		} else if inCluster {
+			namespace, err := inClusterNamespace()
 			if err != nil {
-				return nil, diag.FromErr(fmt.Errorf("failed to configure: %s", err))
+				return diag.Errorf("Vault namespace is not specified, and the pod namespace cannot be read: %v", err)
 			}
+			a.kubeConn.nameSpace = namespace
+		} else {
+			return diag.Errorf("Vault namespace is not specified")
+		}
 
-			a.url = fmt.Sprintf("http://localhost:%s", a.kubeConn.localPort)
+		if service := kubeConn[argServiceName].(string); service != "" {
+			a.kubeConn.serviceName = service
 		} else {
-			if u := d.Get(argVaultAddr).(string); u != "" {
-				a.url = u
-			} else if u := d.Get(argVaultUrl).(string); u != "" {
-				a.url = u
-			} else {
-				a.url = os.Getenv(envVaultAddr)
-			}
+			return diag.Errorf("Vault service name is not specified")
 		}
 
-		if a.url == "" {
-			return nil, diag.Errorf("argument '%s' is required, or set VAULT_ADDR environment variable", argVaultUrl)
+		a.kubeConn.localPort = kubeConn[argLocalPort].(string)
+		a.kubeConn.remotePort = kubeConn[argRemotePort].(string)
+		a.kubeConn.scheme = kubeConn[argScheme].(string)
+		a.kubeConn.podSelection = kubeConn[argPodSelection].(string)
+		a.kubeConn.podName = kubeConn[argPodName].(string)
+		a.kubeConn.podOrdinal = kubeConn[argPodOrdinal].(int)
+		a.kubeConn.transport = kubeConn[argTransport].(string)
+		if a.kubeConn.scheme == "https" && a.kubeConn.transport == transportPortForward {
+			a.kubeConn.caSecret = kubeConn[argCASecret].(string)
 		}
 
-		apiConfig := api.DefaultConfig()
-		apiConfig.Address = a.url
+		if a.kubeConn.podSelection == podSelectionName && a.kubeConn.podName == "" {
+			return diag.Errorf("%q is required when %q is %q", argPodName, argPodSelection, podSelectionName)
+		}
 
-		err := apiConfig.ConfigureTLS(&api.TLSConfig{
-			Insecure: d.Get(argVaultSkipVerify).(bool),
-		})
+		if a.kubeConn.transport == transportServiceProxy && a.kubeConn.podSelection != podSelectionAny {
+			return diag.Errorf("%q cannot be used when %q is %q", argPodSelection, argTransport, transportServiceProxy)
+		}
+
+		if err := expandKubeOverrides(kubeConn, overrides); err != nil {
+			return diag.FromErr(err)
+		}
 
+		var cfg *restclient.Config
+		var err error
+		if inCluster {
+			cfg, err = inClusterConfig()
+		} else {
+			cc := clientcmd.NewNonInteractiveDeferredLoadingClientConfig(loader, overrides)
+			cfg, err = cc.ClientConfig()
+		}
+		if err != nil {
+			return diag.Errorf("invalid Kubernetes configuration: %v", err)
+		}
+
+		cfg.QPS = 100.0
+		cfg.Burst = 100
+		a.kubeConn.kubeConfig = cfg
+
+		a.kubeConn.kubeClient, err = kubernetes.NewForConfig(cfg)
 		if err != nil {
-			logError("failed to configure Vault TLS: %v", err)
-			return nil, diag.FromErr(err)
+			return diag.FromErr(fmt.Errorf("failed to configure: %s", err))
 		}
 
-		if c, err := api.NewClient(apiConfig); err != nil {
-			logError("failed to create Vault API client: %v", err)
-			return nil, diag.FromErr(err)
+		a.kubeConn.tlsServerName = kubeConn[argTLSServerName].(string)
+		a.kubeConn.caSecretKey = kubeConn[argCASecretKey].(string)
+
+		if err := a.configureKube(ctx); err != nil {
+			return kubeDiagnostics(err)
+		}
+	} else {
+		if u := d.Get(argVaultAddr).(string); u != "" {
+			a.url = u
+		} else if u := d.Get(argVaultUrl).(string); u != "" {
+			a.url = u
 		} else {
-			a.client = c
+			a.url = os.Getenv(envVaultAddr)
+		}
+	}
+
+	if a.url == "" {
+		return diag.Errorf("argument '%s' is required, or set VAULT_ADDR environment variable", argVaultUrl)
+	}
+
+	a.tlsConfig = api.TLSConfig{
+		Insecure: d.Get(argVaultSkipVerify).(bool),
+	}
+	a.token = d.Get(argVaultToken).(string)
+
+	if t := d.Get(argSSHTunnel).([]interface{}); len(t) > 0 {
+		var err error
+		if a.tunnel, err = expandSSHTunnel(t[0].(map[string]interface{})); err != nil {
+			return diag.FromErr(err)
 		}
+	}
+
+	if p := d.Get(argProxy).([]interface{}); len(p) > 0 {
+		var err error
+		if a.proxyURL, err = expandProxy(p[0].(map[string]interface{})); err != nil {
+			return diag.FromErr(err)
+		}
+	}
 
-		return a, nil
+	if err := a.configureVault(ctx); err != nil {
+		return kubeDiagnostics(err)
+	}
+
+	return nil
+}
+
+// configureKube checks the Kubernetes permissions, and points the client at
+// the Vault service.
+func (a *apiClient) configureKube(ctx context.Context) error {
+	if err := a.kubeConn.checkPermissions(ctx); err != nil {
+		return err
+	}
+
+	if a.kubeConn.transport == transportServiceProxy {
+		// The API server talks to Vault, and does not verify its certificate,
+		// so the Vault TLS settings do not apply
+		var err error
+		if a.url, err = a.kubeConn.serviceProxyURL(); err != nil {
+			return err
+		}
+	} else {
+		a.url = a.kubeConn.forwardURL(a.kubeConn.localPort)
 	}
+
+	return nil
+}
+
+// configureVault creates the Vault API client for a.url, reaching it through
+// the Kubernetes API, the SSH tunnel or the proxy when configured.
+func (a *apiClient) configureVault(ctx context.Context) error {
+	tlsConfig := a.tlsConfig
+
+	if a.kubeConn.kubeConfig != nil && a.kubeConn.scheme == "https" && a.kubeConn.transport == transportPortForward {
+		// The port forward terminates on localhost, so the certificate has to
+		// be verified against the in-cluster service name instead
+		tlsConfig.TLSServerName = a.kubeConn.tlsServerName
+		if tlsConfig.TLSServerName == "" {
+			tlsConfig.TLSServerName = fmt.Sprintf("%s.%s.svc", a.kubeConn.serviceName, a.kubeConn.nameSpace)
+		}
+
+		if secret := a.kubeConn.caSecret; secret != "" {
+			ca, err := a.kubeConn.caCert(ctx, secret, a.kubeConn.caSecretKey)
+			if err != nil {
+				return err
+			}
+			tlsConfig.CACertBytes = ca
+		}
+	}
+
+	proxyURL := a.proxyURL
+	if proxyURL == nil && a.kubeConn.kubeConfig == nil && a.tunnel == nil {
+		var err error
+		if proxyURL, err = envProxy(a.url); err != nil {
+			return err
+		}
+	}
+
+	apiConfig := api.DefaultConfig()
+	apiConfig.Address = a.url
+
+	if err := apiConfig.ConfigureTLS(&tlsConfig); err != nil {
+		logError("failed to configure Vault TLS: %v", err)
+		return err
+	}
+
+	if a.tunnel != nil {
+		apiConfig.HttpClient.Transport.(*http.Transport).DialContext = a.tunnel.dialContext
+	}
+
+	if proxyURL != nil {
+		if err := configureProxy(apiConfig.HttpClient.Transport.(*http.Transport), proxyURL); err != nil {
+			return err
+		}
+	}
+
+	if a.kubeConn.kubeConfig != nil && a.kubeConn.transport == transportServiceProxy {
+		var err error
+		if apiConfig.HttpClient.Transport, err = a.kubeConn.newServiceProxyTransport(); err != nil {
+			return err
+		}
+	}
+
+	c, err := api.NewClient(apiConfig)
+	if err != nil {
+		logError("failed to create Vault API client: %v", err)
+		return err
+	}
+	// Without a token the client keeps the one of VAULT_TOKEN
+	if a.token != "" {
+		c.SetToken(a.token)
+	}
+	a.client = c
+
+	return nil
+}
+
+// expandKubeOverrides applies the inline credentials and context selection
+// of kube_config on top of the loaded Kubernetes config, the same way the
+// Kubernetes provider does.
+func expandKubeOverrides(kubeConn map[string]interface{}, overrides *clientcmd.ConfigOverrides) error {
+	kubeContext := kubeConn[argKubeConfigContext].(string)
+	authInfo := kubeConn[argKubeConfigContextAuth].(string)
+	cluster := kubeConn[argKubeConfigContextCluster].(string)
+
+	if kubeContext != "" || authInfo != "" || cluster != "" {
+		overrides.CurrentContext = kubeContext
+		overrides.Context = clientcmdapi.Context{
+			AuthInfo: authInfo,
+			Cluster:  cluster,
+		}
+	}
+
+	if v := kubeConn[argKubeClusterCACertificate].(string); v != "" {
+		overrides.ClusterInfo.CertificateAuthorityData = []byte(v)
+	}
+	if v := kubeConn[argKubeClientCertificate].(string); v != "" {
+		overrides.AuthInfo.ClientCertificateData = []byte(v)
+	}
+	if v := kubeConn[argKubeClientKey].(string); v != "" {
+		overrides.AuthInfo.ClientKeyData = []byte(v)
+	}
+	if v := kubeConn[argKubeToken].(string); v != "" {
+		overrides.AuthInfo.Token = v
+	}
+
+	if v := kubeConn[argExec].([]interface{}); len(v) > 0 {
+		spec, ok := v[0].(map[string]interface{})
+		if !ok {
+			return fmt.Errorf("failed to parse %q", argExec)
+		}
+
+		exec := &clientcmdapi.ExecConfig{
+			APIVersion:         spec["api_version"].(string),
+			Command:            spec["command"].(string),
+			Args:               expandStringSlice(spec["args"].([]interface{})),
+			InteractiveMode:    clientcmdapi.ExecInteractiveMode(spec[argExecInteractiveMode].(string)),
+			InstallHint:        spec[argExecInstallHint].(string),
+			ProvideClusterInfo: spec[argExecProvideClusterInfo].(bool),
+		}
+		for kk, vv := range spec["env"].(map[string]interface{}) {
+			exec.Env = append(exec.Env, clientcmdapi.ExecEnvVar{Name: kk, Value: vv.(string)})
+		}
+		overrides.AuthInfo.Exec = exec
+	}
+
+	if v := kubeConn[argKubeHost].(string); v != "" {
+		// The server has to be a complete URL, default the scheme to https
+		// when there is TLS material to use
+		defaultTLS := len(overrides.ClusterInfo.CertificateAuthorityData) != 0 ||
+			len(overrides.AuthInfo.ClientCertificateData) != 0
+		host, _, err := restclient.DefaultServerURL(v, "", k8sschema.GroupVersion{}, defaultTLS)
+		if err != nil {
+			return fmt.Errorf("failed to parse %q: %w", argKubeHost, err)
+		}
+		overrides.ClusterInfo.Server = host.String()
+	}
+
+	return nil
+}
+
+// inClusterNamespace returns the namespace of the pod the provider runs in.
+func inClusterNamespace() (string, error) {
+	namespace, err := os.ReadFile(serviceAccountNamespaceFile)
+	if err != nil {
+		return "", err
+	}
+
+	return strings.TrimSpace(string(namespace)), nil
+}
+
+// forwardURL returns the Vault address for a port forward listening on the
+// given local port.
+func (k *kubeConn) forwardURL(localPort string) string {
+	return fmt.Sprintf("%s://localhost:%s", k.scheme, localPort)
+}
+
+// caCert reads a PEM encoded CA certificate from a Kubernetes Secret in the
+// Vault namespace.
+func (k *kubeConn) caCert(ctx context.Context, secretName, key string) ([]byte, error) {
+	secret, err := k.kubeClient.CoreV1().Secrets(k.nameSpace).Get(ctx, secretName, metav1.GetOptions{})
+	if err != nil {
+		return nil, fmt.Errorf("failed to read CA secret %s/%s: %w", k.nameSpace, secretName, err)
+	}
+
+	ca, ok := secret.Data[key]
+	if !ok || len(ca) == 0 {
+		return nil, fmt.Errorf("CA secret %s/%s has no key %q", k.nameSpace, secretName, key)
+	}
+
+	return ca, nil
+}
+
+// diagsError turns error diagnostics into an error, for the functions of the
+// SDK which return one.
+func diagsError(diags diag.Diagnostics) error {
+	var msgs []string
+	for _, d := range diags {
+		if d.Severity != diag.Error {
+			continue
+		}
+		if d.Detail != "" {
+			msgs = append(msgs, fmt.Sprintf("%s: %s", d.Summary, d.Detail))
+		} else {
+			msgs = append(msgs, d.Summary)
+		}
+	}
+
+	return errors.New(strings.Join(msgs, "; "))
 }
 
 func logError(fmt string, v ...interface{}) {
-	log.Printf("[ERROR] "+fmt, v)
+	log.Printf("[ERROR] "+fmt, v...)
 }
 
 func logInfo(fmt string, v ...interface{}) {
-	log.Printf("[INFO] "+fmt, v)
+	log.Printf("[INFO] "+fmt, v...)
 }
 
 func logDebug(fmt string, v ...interface{}) {
-	log.Printf("[DEBUG] "+fmt, v)
+	log.Printf("[DEBUG] "+fmt, v...)
 }
 
 func homeDir() (string, error) {
@@ -1,12 +1,23 @@
 package provider
 
 import (
+	"bytes"
 	"context"
+	"log"
+	"net/http"
 	"os"
+	"path/filepath"
+	"runtime"
+	"strings"
 	"testing"
 
 	"github.com/hashicorp/terraform-plugin-sdk/v2/helper/schema"
 	"github.com/hashicorp/terraform-plugin-sdk/v2/terraform"
+	v1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/client-go/kubernetes/fake"
+	restclient "k8s.io/client-go/rest"
+	clientcmdapi "k8s.io/client-go/tools/clientcmd/api"
 )
 
 // providerFactories are used to instantiate a provider during acceptance testing.
@@ -24,6 +35,22 @@ func TestProvider(t *testing.T) {
 	}
 }
 
+func TestLog(t *testing.T) {
+	var out bytes.Buffer
+	log.SetOutput(&out)
+	defer log.SetOutput(os.Stderr)
+
+	logError("failed %s: %d", "init", 3)
+	logInfo("no argument")
+
+	if !strings.Contains(out.String(), "[ERROR] failed init: 3\n") {
+		t.Errorf("expected the arguments to be formatted, got %q", out.String())
+	}
+	if !strings.Contains(out.String(), "[INFO] no argument\n") {
+		t.Errorf("expected no argument, got %q", out.String())
+	}
+}
+
 func TestProvider_impl(t *testing.T) {
 	var _ schema.Provider = *New("dev")()
 }
@@ -33,7 +60,7 @@ func TestProvider_configure_url(t *testing.T) {
 
 	rc := terraform.NewResourceConfigRaw(map[string]interface{}{argVaultUrl: "http://localhost:8200"})
 	p := New("dev")()
-	diags := p.Configure(ctx, rc)
+	diags := configureNow(ctx, p, rc)
 	if diags.HasError() {
 		t.Fatal(diags)
 	}
@@ -55,7 +82,7 @@ func TestProvider_configure_url_env(t *testing.T) {
 
 	rc := terraform.NewResourceConfigRaw(map[string]interface{}{})
 	p := New("dev")()
-	diags := p.Configure(ctx, rc)
+	diags := configureNow(ctx, p, rc)
 	if diags.HasError() {
 		t.Fatal(diags)
 	}
@@ -66,7 +93,7 @@ func TestProvider_configure_skip_verify(t *testing.T) {
 
 	rc := terraform.NewResourceConfigRaw(map[string]interface{}{argVaultUrl: "https://localhost:8200", argVaultSkipVerify: true})
 	p := New("dev")()
-	diags := p.Configure(ctx, rc)
+	diags := configureNow(ctx, p, rc)
 	if diags.HasError() {
 		t.Fatal(diags)
 	}
@@ -95,10 +122,114 @@ func TestProvider_configure_skip_verify_env(t *testing.T) {
 
 	rc := terraform.NewResourceConfigRaw(map[string]interface{}{})
 	p := New("dev")()
-	diags := p.Configure(ctx, rc)
+	diags := configureNow(ctx, p, rc)
+	if diags.HasError() {
+		t.Fatal(diags)
+	}
+}
+
+func TestProvider_configure_kube_inline(t *testing.T) {
+	ctx := context.TODO()
+	server, ca, authorizations := startKubeAPI(t, nil)
+
+	rc := terraform.NewResourceConfigRaw(map[string]interface{}{
+		argKubeConfig: []interface{}{map[string]interface{}{
+			argKubeHost:                 server.URL,
+			argKubeToken:                "inline-token",
+			argKubeClusterCACertificate: ca,
+			argNameSpace:                "vault",
+			argServiceName:              "vault",
+		}},
+	})
+	p := New("dev")()
+	diags := configureNow(ctx, p, rc)
 	if diags.HasError() {
 		t.Fatal(diags)
 	}
+
+	kubeConfig := p.Meta().(*apiClient).kubeConn.kubeConfig
+	if kubeConfig.Host != server.URL {
+		t.Fatalf("expected host %q, got %q", server.URL, kubeConfig.Host)
+	}
+	if kubeConfig.BearerToken != "inline-token" {
+		t.Fatalf("expected the inline token, got %q", kubeConfig.BearerToken)
+	}
+	if len(*authorizations) == 0 || (*authorizations)[0] != "Bearer inline-token" {
+		t.Fatalf("expected requests with the inline token, got %v", *authorizations)
+	}
+}
+
+func TestProvider_configure_kube_context(t *testing.T) {
+	ctx := context.TODO()
+	server, ca, _ := startKubeAPI(t, nil)
+	path := writeKubeConfig(t, server.URL, ca, "first", map[string]string{
+		"first":  "first-token",
+		"second": "second-token",
+	})
+
+	cases := []struct {
+		name  string
+		extra map[string]interface{}
+		token string
+	}{
+		{name: "current context", token: "first-token"},
+		{name: "context", extra: map[string]interface{}{argKubeConfigContext: "second"}, token: "second-token"},
+		{name: "auth info", extra: map[string]interface{}{argKubeConfigContextAuth: "second"}, token: "second-token"},
+	}
+
+	for _, c := range cases {
+		t.Run(c.name, func(t *testing.T) {
+			kubeConfig := map[string]interface{}{
+				argKubeConfigPath: path,
+				argNameSpace:      "vault",
+				argServiceName:    "vault",
+			}
+			for k, v := range c.extra {
+				kubeConfig[k] = v
+			}
+
+			rc := terraform.NewResourceConfigRaw(map[string]interface{}{
+				argKubeConfig: []interface{}{kubeConfig},
+			})
+			p := New("dev")()
+			diags := configureNow(ctx, p, rc)
+			if diags.HasError() {
+				t.Fatal(diags)
+			}
+
+			if token := p.Meta().(*apiClient).kubeConn.kubeConfig.BearerToken; token != c.token {
+				t.Fatalf("expected token %q, got %q", c.token, token)
+			}
+		})
+	}
+}
+
+func TestKubeConn_caCert(t *testing.T) {
+	ctx := context.TODO()
+
+	k := &kubeConn{
+		nameSpace: "vault",
+		kubeClient: fake.NewSimpleClientset(&v1.Secret{
+			ObjectMeta: metav1.ObjectMeta{Name: "vault-tls", Namespace: "vault"},
+			Data:       map[string][]byte{"ca.crt": []byte("-----BEGIN CERTIFICATE-----")},
+		}),
+	}
+
+	ca, err := k.caCert(ctx, "vault-tls", "ca.crt")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if string(ca) != "-----BEGIN CERTIFICATE-----" {
+		t.Fatalf("unexpected CA: %q", ca)
+	}
+
+	if _, err := k.caCert(ctx, "vault-tls", "tls.crt"); err == nil {
+		t.Fatal("expected an error for a missing key")
+	}
+
+	if _, err := k.caCert(ctx, "missing", "ca.crt"); err == nil {
+		t.Fatal("expected an error for a missing secret")
+	}
 }
 
 func testAccPreCheck(t *testing.T) {
@@ -109,3 +240,277 @@ func testAccPreCheck(t *testing.T) {
 		t.Fatalf("%s must be set for acceptance tests", envVaultAddr)
 	}
 }
+
+func TestProvider_configure_kube_in_cluster(t *testing.T) {
+	ctx := context.TODO()
+	server, ca, authorizations := startKubeAPI(t, nil)
+
+	namespaceFile := filepath.Join(t.TempDir(), "namespace")
+	if err := os.WriteFile(namespaceFile, []byte("vault-system\n"), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	defaultInClusterConfig, defaultNamespaceFile := inClusterConfig, serviceAccountNamespaceFile
+	defer func() {
+		inClusterConfig, serviceAccountNamespaceFile = defaultInClusterConfig, defaultNamespaceFile
+	}()
+
+	serviceAccountNamespaceFile = namespaceFile
+	inClusterConfig = func() (*restclient.Config, error) {
+		return &restclient.Config{
+			Host:            server.URL,
+			BearerToken:     "service-account-token",
+			TLSClientConfig: restclient.TLSClientConfig{CAData: []byte(ca)},
+		}, nil
+	}
+
+	// No ~/.kube/config, inside a pod
+	t.Setenv("HOME", t.TempDir())
+	t.Setenv(envKubernetesServiceHost, "10.0.0.1")
+
+	cases := []struct {
+		name       string
+		kubeConfig map[string]interface{}
+	}{
+		{name: "flag", kubeConfig: map[string]interface{}{argInCluster: true, argServiceName: "vault"}},
+		{name: "fallback", kubeConfig: map[string]interface{}{argServiceName: "vault"}},
+	}
+
+	for _, c := range cases {
+		t.Run(c.name, func(t *testing.T) {
+			*authorizations = nil
+
+			rc := terraform.NewResourceConfigRaw(map[string]interface{}{
+				argKubeConfig: []interface{}{c.kubeConfig},
+			})
+			p := New("dev")()
+			diags := configureNow(ctx, p, rc)
+			if diags.HasError() {
+				t.Fatal(diags)
+			}
+
+			kubeConn := p.Meta().(*apiClient).kubeConn
+			if kubeConn.nameSpace != "vault-system" {
+				t.Fatalf("expected the pod namespace, got %q", kubeConn.nameSpace)
+			}
+			if len(*authorizations) == 0 || (*authorizations)[0] != "Bearer service-account-token" {
+				t.Fatalf("expected requests with the service account token, got %v", *authorizations)
+			}
+		})
+	}
+}
+
+func TestProvider_configure_kube_exec(t *testing.T) {
+	if runtime.GOOS == "windows" {
+		t.Skip("the stub credential plugin is a shell script")
+	}
+
+	ctx := context.TODO()
+	server, ca, authorizations := startKubeAPI(t, nil)
+
+	// The stub plugin emits the token from its environment, suffixed when
+	// it receives the cluster information
+	plugin := filepath.Join(t.TempDir(), "credential-plugin")
+	script := `#!/bin/sh
+suffix=""
+case "$KUBERNETES_EXEC_INFO" in
+*'"server"'*) suffix="-with-cluster-info" ;;
+esac
+echo '{"apiVersion":"client.authentication.k8s.io/v1beta1","kind":"ExecCredential","status":{"token":"'"${STUB_TOKEN}${suffix}"'"}}'
+`
+	if err := os.WriteFile(plugin, []byte(script), 0755); err != nil {
+		t.Fatal(err)
+	}
+
+	cases := []struct {
+		name               string
+		provideClusterInfo bool
+		token              string
+	}{
+		{name: "token", token: "Bearer exec-token"},
+		{name: "cluster info", provideClusterInfo: true, token: "Bearer exec-token-with-cluster-info"},
+	}
+
+	for _, c := range cases {
+		t.Run(c.name, func(t *testing.T) {
+			*authorizations = nil
+
+			rc := terraform.NewResourceConfigRaw(map[string]interface{}{
+				argKubeConfig: []interface{}{map[string]interface{}{
+					argKubeHost:                 server.URL,
+					argKubeClusterCACertificate: ca,
+					argNameSpace:                "vault",
+					argServiceName:              "vault",
+					argExec: []interface{}{map[string]interface{}{
+						"api_version":             "client.authentication.k8s.io/v1beta1",
+						"command":                 plugin,
+						"env":                     map[string]interface{}{"STUB_TOKEN": "exec-token"},
+						argExecInstallHint:        "install the stub",
+						argExecProvideClusterInfo: c.provideClusterInfo,
+					}},
+				}},
+			})
+			p := New("dev")()
+			diags := configureNow(ctx, p, rc)
+			if diags.HasError() {
+				t.Fatal(diags)
+			}
+
+			exec := p.Meta().(*apiClient).kubeConn.kubeConfig.ExecProvider
+			if exec == nil || exec.InteractiveMode != clientcmdapi.NeverExecInteractiveMode || exec.InstallHint != "install the stub" {
+				t.Fatalf("unexpected exec provider: %v", exec)
+			}
+			if len(*authorizations) == 0 || (*authorizations)[0] != c.token {
+				t.Fatalf("expected requests with %q, got %v", c.token, *authorizations)
+			}
+		})
+	}
+}
+
+func TestProvider_configure_kube_service_proxy(t *testing.T) {
+	ctx := context.TODO()
+
+	var paths []string
+	server, ca, authorizations := startKubeAPI(t, http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		paths = append(paths, r.URL.Path)
+		w.Header().Set("Content-Type", "application/json")
+		w.Write([]byte(`{"initialized": true}`))
+	}))
+
+	rc := terraform.NewResourceConfigRaw(map[string]interface{}{
+		argKubeConfig: []interface{}{map[string]interface{}{
+			argKubeHost:                 server.URL,
+			argKubeToken:                "proxy-token",
+			argKubeClusterCACertificate: ca,
+			argNameSpace:                "vault",
+			argServiceName:              "vault",
+			argScheme:                   "https",
+			argTransport:                transportServiceProxy,
+		}},
+	})
+	p := New("dev")()
+	diags := configureNow(ctx, p, rc)
+	if diags.HasError() {
+		t.Fatal(diags)
+	}
+
+	client := p.Meta().(*apiClient)
+	expected := server.URL + "/api/v1/namespaces/vault/services/https:vault:8200/proxy"
+	if client.url != expected {
+		t.Fatalf("expected url %s, got %s", expected, client.url)
+	}
+
+	*authorizations = nil
+	initialized, err := client.client.Sys().InitStatus()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !initialized {
+		t.Fatal("expected the init status from the proxied Vault")
+	}
+
+	if len(paths) != 1 || paths[0] != "/api/v1/namespaces/vault/services/https:vault:8200/proxy/v1/sys/init" {
+		t.Fatalf("unexpected proxied requests %v", paths)
+	}
+	if len(*authorizations) != 1 || (*authorizations)[0] != "Bearer proxy-token" {
+		t.Fatalf("expected the Kubernetes credentials on the proxied request, got %v", *authorizations)
+	}
+
+	// Requests to other hosts must not carry the Kubernetes credentials
+	other, err := client.client.Clone()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := other.SetAddress("https://vault-1.vault.svc:8200"); err != nil {
+		t.Fatal(err)
+	}
+	other.SetMaxRetries(0)
+	if _, err := other.Sys().InitStatus(); err == nil || !strings.Contains(err.Error(), "refusing") {
+		t.Fatalf("expected the request to another host to be refused, got %v", err)
+	}
+}
+
+func TestProvider_configure_kube_service_proxy_pod_selection(t *testing.T) {
+	rc := terraform.NewResourceConfigRaw(map[string]interface{}{
+		argKubeConfig: []interface{}{map[string]interface{}{
+			argKubeHost:     "https://127.0.0.1:6443",
+			argNameSpace:    "vault",
+			argServiceName:  "vault",
+			argTransport:    transportServiceProxy,
+			argPodSelection: podSelectionActive,
+		}},
+	})
+	p := New("dev")()
+	diags := configureNow(context.TODO(), p, rc)
+	if !diags.HasError() {
+		t.Fatal("expected pod_selection to be rejected with the service proxy")
+	}
+}
+
+func TestProvider_configure_deferred(t *testing.T) {
+	ctx := context.TODO()
+	t.Setenv(envVaultAddr, "")
+
+	// How the SDK represents values unknown until apply
+	const unknown = "74D93920-ED26-11E3-AC10-0800200C9A66"
+
+	cases := []struct {
+		name string
+		raw  map[string]interface{}
+		err  string
+	}{
+		{
+			name: "unknown address",
+			raw:  map[string]interface{}{argVaultAddr: unknown},
+		},
+		{
+			name: "unknown kube host",
+			raw: map[string]interface{}{
+				argKubeConfig: []interface{}{map[string]interface{}{
+					argKubeHost:    unknown,
+					argKubeToken:   unknown,
+					argNameSpace:   "vault",
+					argServiceName: "vault",
+				}},
+			},
+		},
+		{
+			name: "missing address",
+			raw:  map[string]interface{}{},
+			err:  "is required",
+		},
+		{
+			name: "missing kube config",
+			raw: map[string]interface{}{
+				argKubeConfig: []interface{}{map[string]interface{}{
+					argKubeConfigPath: filepath.Join(t.TempDir(), "missing"),
+					argNameSpace:      "vault",
+					argServiceName:    "vault",
+				}},
+			},
+			err: "invalid Kubernetes configuration",
+		},
+	}
+
+	for _, c := range cases {
+		t.Run(c.name, func(t *testing.T) {
+			p := New("dev")()
+			if diags := p.Configure(ctx, terraform.NewResourceConfigRaw(c.raw)); diags.HasError() {
+				t.Fatalf("expected the configuration to be deferred, got %v", diags)
+			}
+
+			if c.err == "" {
+				return
+			}
+
+			// The configuration errors surface on first use, every time
+			for i := 0; i < 2; i++ {
+				d := schema.TestResourceDataRaw(t, providerDatasource().Schema, map[string]interface{}{})
+				diags := providerDatasourceRead(ctx, d, p.Meta())
+				if !diags.HasError() || !strings.Contains(diags[0].Summary, c.err) {
+					t.Fatalf("expected an error containing %q, got %v", c.err, diags)
+				}
+			}
+		})
+	}
+}
@@ -0,0 +1,101 @@
+package provider
+
+import (
+	"fmt"
+	"net/http"
+	"net/url"
+	"os"
+	"strings"
+
+	"golang.org/x/net/http/httpproxy"
+	"golang.org/x/net/proxy"
+)
+
+const (
+	argProxy         = "proxy"
+	argProxyURL      = "url"
+	argProxyUsername = "username"
+	argProxyPassword = "password"
+
+	envAllProxy = "ALL_PROXY"
+	envNoProxy  = "NO_PROXY"
+)
+
+// expandProxy returns the proxy URL of the proxy block, with its credentials.
+func expandProxy(spec map[string]interface{}) (*url.URL, error) {
+	u, err := url.Parse(spec[argProxyURL].(string))
+	if err != nil {
+		return nil, fmt.Errorf("failed to parse %q: %w", argProxyURL, err)
+	}
+
+	if username := spec[argProxyUsername].(string); username != "" {
+		u.User = url.UserPassword(username, spec[argProxyPassword].(string))
+	}
+
+	return u, nil
+}
+
+// envProxy returns the proxy from ALL_PROXY to reach vaultAddr through,
+// unless NO_PROXY excludes it. The lower case variables are read too, as
+// curl does.
+func envProxy(vaultAddr string) (*url.URL, error) {
+	allProxy := getenvAnyCase(envAllProxy)
+	if allProxy == "" {
+		return nil, nil
+	}
+
+	target, err := url.Parse(vaultAddr)
+	if err != nil {
+		return nil, err
+	}
+
+	// httpproxy implements the NO_PROXY matching rules
+	config := &httpproxy.Config{
+		HTTPProxy:  allProxy,
+		HTTPSProxy: allProxy,
+		NoProxy:    getenvAnyCase(envNoProxy),
+	}
+	if u, err := config.ProxyFunc()(target); err != nil || u == nil {
+		return nil, err
+	}
+
+	u, err := url.Parse(allProxy)
+	if err != nil {
+		return nil, fmt.Errorf("failed to parse %s: %w", envAllProxy, err)
+	}
+
+	return u, nil
+}
+
+// configureProxy makes transport reach Vault through the proxy at u: SOCKS5
+// proxies dial the connections, HTTP proxies tunnel them with CONNECT. TLS
+// to Vault is end to end either way.
+func configureProxy(transport *http.Transport, u *url.URL) error {
+	switch u.Scheme {
+	case "socks5", "socks5h":
+		dialer, err := proxy.FromURL(u, proxy.Direct)
+		if err != nil {
+			return err
+		}
+
+		// The SOCKS5 dialer of x/net always implements ContextDialer
+		transport.DialContext = dialer.(proxy.ContextDialer).DialContext
+		transport.Proxy = nil
+	case "http", "https":
+		transport.Proxy = http.ProxyURL(u)
+	default:
+		return fmt.Errorf("unsupported proxy scheme %q, expected socks5, socks5h, http or https", u.Scheme)
+	}
+
+	logDebug("reaching Vault through proxy %s://%s", u.Scheme, u.Host)
+
+	return nil
+}
+
+func getenvAnyCase(key string) string {
+	if v := os.Getenv(key); v != "" {
+		return v
+	}
+
+	return os.Getenv(strings.ToLower(key))
+}
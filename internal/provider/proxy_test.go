@@ -0,0 +1,167 @@
+package provider
+
+import (
+	"context"
+	"encoding/pem"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/hashicorp/terraform-plugin-sdk/v2/terraform"
+)
+
+func TestProvider_configure_proxy(t *testing.T) {
+	vault := httptest.NewTLSServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/json")
+		w.Write([]byte(`{"initialized": true}`))
+	}))
+	defer vault.Close()
+	vaultHost := strings.TrimPrefix(vault.URL, "https://")
+
+	// Vault's certificate is verified, end to end through the proxy
+	caCert := filepath.Join(t.TempDir(), "ca.pem")
+	ca := pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: vault.Certificate().Raw})
+	if err := os.WriteFile(caCert, ca, 0600); err != nil {
+		t.Fatal(err)
+	}
+	t.Setenv("VAULT_CACERT", caCert)
+
+	// Go never proxies loopback addresses read from the environment, so Vault
+	// is addressed by a name its certificate is valid for, resolved by the proxy
+	hosts := map[string]string{"example.com": "127.0.0.1"}
+	_, port, err := net.SplitHostPort(vaultHost)
+	if err != nil {
+		t.Fatal(err)
+	}
+	vaultName := net.JoinHostPort("example.com", port)
+
+	socks, socksConnects := startSOCKS5Proxy(t, "", "", hosts)
+	socksAuth, socksAuthConnects := startSOCKS5Proxy(t, "proxy-user", "proxy-password", hosts)
+	connect, connectConnects := startConnectProxy(t)
+
+	cases := []struct {
+		name     string
+		addr     string
+		proxy    map[string]interface{}
+		allProxy string
+		connects *[]string
+	}{
+		{
+			name:     "socks5",
+			proxy:    map[string]interface{}{argProxyURL: "socks5://" + socks},
+			connects: socksConnects,
+		},
+		{
+			name: "socks5 credentials",
+			proxy: map[string]interface{}{
+				argProxyURL:      "socks5h://" + socksAuth,
+				argProxyUsername: "proxy-user",
+				argProxyPassword: "proxy-password",
+			},
+			connects: socksAuthConnects,
+		},
+		{
+			name:     "http connect",
+			proxy:    map[string]interface{}{argProxyURL: connect.URL},
+			connects: connectConnects,
+		},
+		{
+			name:     "all proxy",
+			addr:     vaultName,
+			allProxy: "socks5h://" + socks,
+			connects: socksConnects,
+		},
+	}
+
+	for _, c := range cases {
+		t.Run(c.name, func(t *testing.T) {
+			*socksConnects = nil
+			*socksAuthConnects = nil
+			*connectConnects = nil
+			t.Setenv(envAllProxy, c.allProxy)
+			t.Setenv(envNoProxy, "")
+
+			addr := c.addr
+			if addr == "" {
+				addr = vaultHost
+			}
+
+			raw := map[string]interface{}{
+				argVaultAddr: "https://" + addr,
+			}
+			if c.proxy != nil {
+				raw[argProxy] = []interface{}{c.proxy}
+			}
+
+			p := New("dev")()
+			diags := configureNow(context.TODO(), p, terraform.NewResourceConfigRaw(raw))
+			if diags.HasError() {
+				t.Fatal(diags)
+			}
+
+			initialized, err := p.Meta().(*apiClient).client.Sys().InitStatus()
+			if err != nil {
+				t.Fatal(err)
+			}
+			if !initialized {
+				t.Fatal("expected the init status from Vault")
+			}
+
+			connects := len(*socksConnects) + len(*socksAuthConnects) + len(*connectConnects)
+			if connects != 1 || len(*c.connects) != 1 || (*c.connects)[0] != addr {
+				t.Fatalf("expected one connection to %s through the proxy, got %v", addr, *c.connects)
+			}
+		})
+	}
+
+	t.Run("unsupported scheme", func(t *testing.T) {
+		p := New("dev")()
+		diags := configureNow(context.TODO(), p, terraform.NewResourceConfigRaw(map[string]interface{}{
+			argVaultAddr: vault.URL,
+			argProxy:     []interface{}{map[string]interface{}{argProxyURL: "ftp://" + socks}},
+		}))
+		if !diags.HasError() {
+			t.Fatal("expected the proxy scheme to be rejected")
+		}
+	})
+}
+
+func TestEnvProxy(t *testing.T) {
+	cases := []struct {
+		name      string
+		allProxy  string
+		noProxy   string
+		vaultAddr string
+		expected  string
+	}{
+		{name: "unset", vaultAddr: "https://vault.internal:8200"},
+		{name: "proxied", allProxy: "socks5h://proxy:1080", vaultAddr: "https://vault.internal:8200", expected: "socks5h://proxy:1080"},
+		{name: "no proxy domain", allProxy: "socks5h://proxy:1080", noProxy: ".internal", vaultAddr: "https://vault.internal:8200"},
+		{name: "no proxy other domain", allProxy: "http://proxy:3128", noProxy: ".example.com", vaultAddr: "https://vault.internal:8200", expected: "http://proxy:3128"},
+		{name: "loopback", allProxy: "socks5h://proxy:1080", vaultAddr: "http://127.0.0.1:8200"},
+	}
+
+	for _, c := range cases {
+		t.Run(c.name, func(t *testing.T) {
+			t.Setenv(envAllProxy, c.allProxy)
+			t.Setenv(envNoProxy, c.noProxy)
+
+			u, err := envProxy(c.vaultAddr)
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			actual := ""
+			if u != nil {
+				actual = u.String()
+			}
+			if actual != c.expected {
+				t.Fatalf("expected proxy %q, got %q", c.expected, actual)
+			}
+		})
+	}
+}
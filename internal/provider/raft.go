@@ -0,0 +1,158 @@
+package provider
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"io"
+
+	"github.com/hashicorp/terraform-plugin-sdk/v2/helper/schema"
+	"github.com/hashicorp/vault/api"
+)
+
+const (
+	pathRaftConfiguration = "sys/storage/raft/configuration"
+	pathRaftRemovePeer    = "sys/storage/raft/remove-peer"
+
+	argServers         = "servers"
+	argLeader          = "leader"
+	argProtocolVersion = "protocol_version"
+)
+
+// raftServer is a peer of the raft cluster, as listed by
+// sys/storage/raft/configuration
+type raftServer struct {
+	NodeID          string `json:"node_id"`
+	Address         string `json:"address"`
+	Leader          bool   `json:"leader"`
+	ProtocolVersion string `json:"protocol_version"`
+	Voter           bool   `json:"voter"`
+}
+
+// raftConfiguration returns the peers of the raft cluster the node behind c
+// belongs to.
+func raftConfiguration(ctx context.Context, c *api.Client) ([]raftServer, error) {
+	secret, err := c.Logical().ReadWithContext(ctx, pathRaftConfiguration)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read the raft configuration: %w", err)
+	}
+	if secret == nil || secret.Data == nil {
+		return nil, fmt.Errorf("failed to read the raft configuration: empty response")
+	}
+
+	raw, err := json.Marshal(secret.Data["config"])
+	if err != nil {
+		return nil, err
+	}
+
+	var config struct {
+		Servers []raftServer `json:"servers"`
+	}
+	if err := json.Unmarshal(raw, &config); err != nil {
+		return nil, fmt.Errorf("failed to parse the raft configuration: %w", err)
+	}
+
+	return config.Servers, nil
+}
+
+// findRaftServer returns the peer with the given node ID, or nil.
+func findRaftServer(servers []raftServer, nodeID string) *raftServer {
+	for i := range servers {
+		if servers[i].NodeID == nodeID {
+			return &servers[i]
+		}
+	}
+
+	return nil
+}
+
+// removeRaftPeer removes the peer with the given node ID from the raft
+// cluster.
+func removeRaftPeer(ctx context.Context, c *api.Client, nodeID string) error {
+	_, err := c.Logical().WriteWithContext(ctx, pathRaftRemovePeer, map[string]interface{}{
+		"server_id": nodeID,
+	})
+	if err != nil {
+		return fmt.Errorf("failed to remove raft peer %s: %w", nodeID, err)
+	}
+
+	return nil
+}
+
+// raftServersSchema is the computed list of the peers of the raft cluster.
+func raftServersSchema() *schema.Schema {
+	return &schema.Schema{
+		Description: "The peers of the raft cluster.",
+		Type:        schema.TypeList,
+		Computed:    true,
+		Elem: &schema.Resource{
+			Schema: map[string]*schema.Schema{
+				argNodeID: {
+					Description: "The raft node ID of the peer.",
+					Type:        schema.TypeString,
+					Computed:    true,
+				},
+				argAddress: {
+					Description: "The cluster address of the peer.",
+					Type:        schema.TypeString,
+					Computed:    true,
+				},
+				argLeader: {
+					Description: "Whether the peer is the leader of the cluster.",
+					Type:        schema.TypeBool,
+					Computed:    true,
+				},
+				argVoter: {
+					Description: "Whether the peer is a voter.",
+					Type:        schema.TypeBool,
+					Computed:    true,
+				},
+				argProtocolVersion: {
+					Description: "The raft protocol version of the peer.",
+					Type:        schema.TypeString,
+					Computed:    true,
+				},
+			},
+		},
+	}
+}
+
+func flattenRaftServers(servers []raftServer) []interface{} {
+	result := make([]interface{}, len(servers))
+	for i, s := range servers {
+		result[i] = map[string]interface{}{
+			argNodeID:          s.NodeID,
+			argAddress:         s.Address,
+			argLeader:          s.Leader,
+			argVoter:           s.Voter,
+			argProtocolVersion: s.ProtocolVersion,
+		}
+	}
+
+	return result
+}
+
+// countingWriter counts the bytes written through it.
+type countingWriter struct {
+	n int64
+}
+
+func (w *countingWriter) Write(p []byte) (int, error) {
+	w.n += int64(len(p))
+	return len(p), nil
+}
+
+// writeRaftSnapshot streams a snapshot of the raft cluster to w, and returns
+// its size and hex encoded SHA-256.
+func writeRaftSnapshot(ctx context.Context, c *api.Client, w io.Writer) (int64, string, error) {
+	hash := sha256.New()
+	size := &countingWriter{}
+
+	if err := c.Sys().RaftSnapshotWithContext(ctx, io.MultiWriter(w, hash, size)); err != nil {
+		return 0, "", fmt.Errorf("failed to take the raft snapshot: %w", err)
+	}
+
+	return size.n, hex.EncodeToString(hash.Sum(nil)), nil
+}
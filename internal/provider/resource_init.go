@@ -6,21 +6,15 @@ import (
 	"errors"
 	"fmt"
 	"io/ioutil"
-	"net/http"
 	"net/url"
-	"os"
-	"os/signal"
 	"path/filepath"
-	"syscall"
+	"time"
 
 	"github.com/hashicorp/terraform-plugin-sdk/v2/diag"
 	"github.com/hashicorp/terraform-plugin-sdk/v2/helper/schema"
 	"github.com/hashicorp/vault/api"
 	v1 "k8s.io/api/core/v1"
-	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
 	_ "k8s.io/client-go/plugin/pkg/client/auth/gcp"
-	"k8s.io/client-go/tools/portforward"
-	"k8s.io/client-go/transport/spdy"
 )
 
 const (
@@ -37,6 +31,12 @@ const (
 	argPGPKeys            = "pgp_keys"
 	argRecoveryPGPKeys    = "recovery_pgp_keys"
 	argRootTokenPGPKey    = "root_token_pgp_key"
+	argClusterWide        = "cluster_wide"
+	argPods               = "pods"
+	argSealed             = "sealed"
+	argName               = "name"
+
+	unsealPollInterval = 2 * time.Second
 )
 
 func resourceInit() *schema.Resource {
@@ -51,6 +51,9 @@ func resourceInit() *schema.Resource {
 		Importer: &schema.ResourceImporter{
 			StateContext: resourceInitImporter,
 		},
+		Timeouts: &schema.ResourceTimeout{
+			Create: schema.DefaultTimeout(10 * time.Minute),
+		},
 
 		Schema: map[string]*schema.Schema{
 			argSecretShares: {
@@ -94,6 +97,36 @@ func resourceInit() *schema.Resource {
 				Type:        schema.TypeString,
 				Optional:    true,
 			},
+			argVaultConnection: connectionSchema(),
+			argClusterWide: {
+				Description: "Initialize one pod behind the Kubernetes service, then unseal every pod with the resulting keys. The ID is then `<service>.<namespace>`. Requires `kube_config` on the provider, and cannot be used with `pgp_keys`.",
+				Type:        schema.TypeBool,
+				Optional:    true,
+			},
+			argPods: {
+				Description: "The status of each pod behind the Kubernetes service, when `cluster_wide` is set.",
+				Type:        schema.TypeList,
+				Computed:    true,
+				Elem: &schema.Resource{
+					Schema: map[string]*schema.Schema{
+						argName: {
+							Description: "The pod name.",
+							Type:        schema.TypeString,
+							Computed:    true,
+						},
+						argInitialized: {
+							Description: "Whether Vault on the pod is initialized.",
+							Type:        schema.TypeBool,
+							Computed:    true,
+						},
+						argSealed: {
+							Description: "Whether Vault on the pod is sealed.",
+							Type:        schema.TypeBool,
+							Computed:    true,
+						},
+					},
+				},
+			},
 			argRootToken: {
 				Description: "The Vault Root Token.",
 				Type:        schema.TypeString,
@@ -142,7 +175,10 @@ func resourceInit() *schema.Resource {
 
 func resourceInitCreate(ctx context.Context, d *schema.ResourceData, meta interface{}) diag.Diagnostics {
 	// use the meta value to retrieve your client from the provider configure method
-	client := meta.(*apiClient)
+	client, diags := meta.(*apiClient).connection(ctx, d)
+	if diags.HasError() {
+		return diags
+	}
 	secretShares := d.Get(argSecretShares).(int)
 	secretThreshold := d.Get(argSecretThreshold).(int)
 	recoveryShares := d.Get(argRecoveryShares).(int)
@@ -161,145 +197,183 @@ func resourceInitCreate(ctx context.Context, d *schema.ResourceData, meta interf
 		recoveryPGPKeysList[i] = pgpKey.(string)
 	}
 
-	// stopCh control the port forwarding lifecycle. When it gets closed the
-	// port forward will terminate
-	stopCh := make(chan struct{}, 1)
-	// readyCh communicate when the port forward is ready to get traffic
-	readyCh := make(chan struct{})
-
-	if kubeConfig := client.kubeConn.kubeConfig; kubeConfig != nil {
-		kubeClientSet := client.kubeConn.kubeClient
-		nameSpace := client.kubeConn.nameSpace
-		serviceName := client.kubeConn.serviceName
-		localPort := client.kubeConn.localPort
-		remotePort := client.kubeConn.remotePort
-
-		errCh := make(chan error, 1)
-
-		// managing termination signal from the terminal. As you can see the stopCh
-		// gets closed to gracefully handle its termination.
-		sigs := make(chan os.Signal, 1)
-		signal.Notify(sigs, syscall.SIGINT, syscall.SIGTERM)
-		go func() {
-			<-sigs
-			logInfo("Stopping a forward process...")
-			close(stopCh)
-		}()
-
-		go func() {
-			svc, err := kubeClientSet.CoreV1().Services(nameSpace).Get(ctx, serviceName, metav1.GetOptions{})
-			if err != nil {
-				logDebug("failed to create Kubernetes client")
-				errCh <- err
-			}
+	req := api.InitRequest{
+		SecretShares:      secretShares,
+		SecretThreshold:   secretThreshold,
+		RecoveryShares:    recoveryShares,
+		RecoveryThreshold: recoveryThreshold,
+		PGPKeys:           pgpKeysList,
+		RecoveryPGPKeys:   recoveryPGPKeysList,
+		RootTokenPGPKey:   rootTokenPGPKey,
+	}
 
-			selector := mapToSelectorStr(svc.Spec.Selector)
-			if selector == "" {
-				logDebug("failed to get service selector")
-				errCh <- err
-			}
+	if d.Get(argClusterWide).(bool) {
+		return initClusterWide(ctx, d, client, &req)
+	}
 
-			pods, err := kubeClientSet.CoreV1().Pods(svc.Namespace).List(ctx, metav1.ListOptions{LabelSelector: selector})
-			if err != nil {
-				logDebug("failed to get a pod list")
-				errCh <- err
-			}
+	vaultClient, closeVault, diags := client.vault(ctx)
+	if diags.HasError() {
+		return diags
+	}
+	defer closeVault()
 
-			if len(pods.Items) == 0 {
-				logDebug("no Vault pods was found")
-				errCh <- err
-			}
+	res, err := vaultClient.Sys().Init(&req)
 
-			livePod, err := getPodName(pods)
-			if err != nil {
-				logDebug("failed to get live Vault pod")
-				errCh <- err
-			}
-
-			serverURL, err := url.Parse(
-				fmt.Sprintf("%s/api/v1/namespaces/%s/pods/%s/portforward", kubeConfig.Host, nameSpace, livePod))
-			if err != nil {
-				logDebug("failed to construct server url")
-				errCh <- err
-			}
+	if err != nil {
+		logError("failed to initialize Vault: %v", err)
+		return diag.FromErr(err)
+	}
 
-			transport, upgrader, err := spdy.RoundTripperFor(kubeConfig)
-			if err != nil {
-				logDebug("failed to create a round tripper")
-				errCh <- err
-			}
+	if err := updateState(d, client.url, res); err != nil {
+		logError("failed to update state: %v", err)
+		return diag.FromErr(err)
+	}
 
-			dialer := spdy.NewDialer(upgrader, &http.Client{Transport: transport}, http.MethodPost, serverURL)
+	return diag.Diagnostics{}
+}
 
-			addresses := []string{"127.0.0.1"}
-			ports := []string{fmt.Sprintf("%s:%s", localPort, remotePort)}
+// initClusterWide initializes one pod behind the Kubernetes service, then
+// unseals every pod with the resulting keys, waiting for each to join the
+// cluster and report unsealed.
+func initClusterWide(ctx context.Context, d *schema.ResourceData, client *apiClient, req *api.InitRequest) diag.Diagnostics {
+	if client.kubeConn.kubeConfig == nil {
+		return diag.Errorf("%q requires %q to be configured on the provider", argClusterWide, argKubeConfig)
+	}
+	if !client.kubeConn.portForwarding() {
+		return diag.Errorf("%q cannot be used when %q is %q", argClusterWide, argTransport, transportServiceProxy)
+	}
+	if len(req.PGPKeys) > 0 {
+		return diag.Errorf("%q cannot unseal with PGP encrypted keys, remove %q", argClusterWide, argPGPKeys)
+	}
 
-			pf, err := portforward.NewOnAddresses(
-				dialer,
-				addresses,
-				ports,
-				stopCh,
-				readyCh,
-				os.Stdout,
-				os.Stderr)
-			if err != nil {
-				logDebug("failed to create port-forward: %s:%s", localPort, remotePort)
-				errCh <- err
-			}
+	svc, pods, err := client.kubeConn.vaultPods(ctx)
+	if err != nil {
+		return kubeDiagnostics(err)
+	}
 
-			go pf.ForwardPorts()
+	initPod, err := client.kubeConn.selectPod(ctx, svc, pods)
+	if err != nil {
+		return kubeDiagnostics(err)
+	}
 
-			<-readyCh
+	// Open one port forward per running pod, on a free local port each
+	clients := map[string]*api.Client{}
+	var names []string
+	for i := range pods.Items {
+		pod := &pods.Items[i]
+		if pod.Status.Phase != v1.PodRunning {
+			continue
+		}
 
-			actualPorts, err := pf.GetPorts()
-			if err != nil {
-				logDebug("failed to get port-forward ports")
-				errCh <- err
-			}
-			if len(actualPorts) != 1 {
-				logDebug("cannot get forwarded ports: unexpected length %d", len(actualPorts))
-				errCh <- err
-			}
-		}()
+		f, err := client.kubeConn.forward(ctx, svc, pod, "0")
+		if err != nil {
+			return kubeDiagnostics(err)
+		}
+		defer f.close()
 
-		select {
-		case <-readyCh:
-			logDebug("Port-forwarding is ready to handle traffic")
-			break
-		case err := <-errCh:
+		if clients[pod.Name], err = client.kubeConn.vaultClient(client.client, f); err != nil {
 			return diag.FromErr(err)
 		}
-	}
 
-	req := api.InitRequest{
-		SecretShares:      secretShares,
-		SecretThreshold:   secretThreshold,
-		RecoveryShares:    recoveryShares,
-		RecoveryThreshold: recoveryThreshold,
-		PGPKeys:           pgpKeysList,
-		RecoveryPGPKeys:   recoveryPGPKeysList,
-		RootTokenPGPKey:   rootTokenPGPKey,
+		// The initialized pod has to be unsealed before the others can join it
+		if pod.Name == initPod.Name {
+			names = append([]string{pod.Name}, names...)
+		} else {
+			names = append(names, pod.Name)
+		}
 	}
 
-	logDebug("request: %v", req)
-
-	res, err := client.client.Sys().Init(&req)
+	logInfo("initializing Vault pod %s", initPod.Name)
 
+	res, err := clients[initPod.Name].Sys().InitWithContext(ctx, req)
 	if err != nil {
 		logError("failed to initialize Vault: %v", err)
 		return diag.FromErr(err)
 	}
 
-	logDebug("response: %v", res)
-
-	if err := updateState(d, client.client.Address(), res); err != nil {
+	// Save the keys before unsealing, so they are kept whatever happens next.
+	// The URL of the client is a local port forward, the service identifies
+	// the cluster.
+	if err := updateState(d, fmt.Sprintf("%s.%s", svc.Name, svc.Namespace), res); err != nil {
 		logError("failed to update state: %v", err)
 		return diag.FromErr(err)
 	}
 
-	close(stopCh)
+	var diags diag.Diagnostics
+	var statuses []interface{}
+	deadline := time.Now().Add(d.Timeout(schema.TimeoutCreate))
+
+	for _, name := range names {
+		status, err := unsealPod(ctx, clients[name], res.Keys, deadline)
+		if err != nil {
+			logError("failed to unseal Vault pod %s: %v", name, err)
+			diags = append(diags, diag.Diagnostic{
+				Severity: diag.Warning,
+				Summary:  fmt.Sprintf("Failed to unseal Vault pod %s", name),
+				Detail:   err.Error(),
+			})
+		}
 
-	return diag.Diagnostics{}
+		podStatus := map[string]interface{}{
+			argName:        name,
+			argInitialized: false,
+			argSealed:      true,
+		}
+		if status != nil {
+			podStatus[argInitialized] = status.Initialized
+			podStatus[argSealed] = status.Sealed
+		}
+		statuses = append(statuses, podStatus)
+	}
+
+	if err := d.Set(argPods, statuses); err != nil {
+		return append(diags, diag.FromErr(err)...)
+	}
+
+	return diags
+}
+
+// unsealPod submits the unseal keys to a Vault node until it reports
+// unsealed. Nodes that join a raft cluster through retry_join only report
+// unsealed once they have joined, so their status is polled until deadline.
+// Errors, like those of a restarting node, are retried until deadline too.
+func unsealPod(ctx context.Context, c *api.Client, keys []string, deadline time.Time) (*api.SealStatusResponse, error) {
+	// With auto unseal there are no keys, the node unseals on its own
+	submitted := len(keys) == 0
+
+	for {
+		status, err := c.Sys().SealStatusWithContext(ctx)
+		if err == nil && status.Sealed && !submitted {
+			for _, key := range keys {
+				if status, err = c.Sys().UnsealWithContext(ctx, key); err != nil || !status.Sealed {
+					break
+				}
+			}
+			// Keys submitted again are ignored by Vault, so a failed
+			// submission is retried from the first key
+			submitted = err == nil
+		}
+
+		if err == nil && status.Initialized && !status.Sealed {
+			return status, nil
+		}
+		if err != nil {
+			logDebug("failed to unseal Vault, retrying: %v", err)
+		}
+
+		if time.Now().After(deadline) {
+			if err != nil {
+				return nil, fmt.Errorf("timed out waiting for Vault to unseal: %w", err)
+			}
+			return status, fmt.Errorf("timed out waiting for Vault to unseal")
+		}
+
+		select {
+		case <-ctx.Done():
+			return nil, ctx.Err()
+		case <-time.After(unsealPollInterval):
+		}
+	}
 }
 
 func resourceInitRead(ctx context.Context, d *schema.ResourceData, meta interface{}) diag.Diagnostics {
@@ -324,7 +398,10 @@ func resourceInitDelete(ctx context.Context, d *schema.ResourceData, meta interf
 }
 
 func resourceInitImporter(c context.Context, d *schema.ResourceData, meta interface{}) ([]*schema.ResourceData, error) {
-	client := meta.(*apiClient)
+	client, diags := meta.(*apiClient).connection(c, d)
+	if diags.HasError() {
+		return nil, diagsError(diags)
+	}
 	// Id should be a file scheme URL: file://path_to_file.json
 	// The json file schema should be the same as what's returned from the sys/init API (i.e. a InitResponse)
 	id := d.Id()
@@ -381,28 +458,3 @@ func updateState(d *schema.ResourceData, id string, res *api.InitResponse) error
 
 	return nil
 }
-
-func getPodName(pods *v1.PodList) (string, error) {
-
-	for _, pod := range pods.Items {
-		if pod.Status.Phase != v1.PodRunning {
-			continue
-		}
-
-		return pod.Name, nil
-	}
-
-	return "", fmt.Errorf("no live pods behind the service")
-}
-
-func mapToSelectorStr(msel map[string]string) string {
-	selector := ""
-	for k, v := range msel {
-		if selector != "" {
-			selector = selector + ","
-		}
-		selector = selector + fmt.Sprintf("%s=%s", k, v)
-	}
-
-	return selector
-}
@@ -1,13 +1,22 @@
 package provider
 
 import (
+	"context"
 	"encoding/base64"
+	"encoding/json"
 	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"reflect"
 	"regexp"
+	"sync"
 	"testing"
+	"time"
 
 	"github.com/ProtonMail/gopenpgp/v2/crypto"
 	"github.com/hashicorp/terraform-plugin-sdk/v2/helper/resource"
+	"github.com/hashicorp/terraform-plugin-sdk/v2/helper/schema"
+	"github.com/hashicorp/vault/api"
 )
 
 var testAccResourceInitVar = fmt.Sprintf("%[1]s.test", resInit)
@@ -142,3 +151,181 @@ resource "%[2]s" "test" {
 		},
 	})
 }
+
+func TestUnsealPod(t *testing.T) {
+	var submitted []string
+
+	mux := http.NewServeMux()
+	mux.HandleFunc("/v1/sys/seal-status", func(w http.ResponseWriter, r *http.Request) {
+		json.NewEncoder(w).Encode(api.SealStatusResponse{Initialized: true, Sealed: len(submitted) < 3, T: 3, N: 5})
+	})
+	mux.HandleFunc("/v1/sys/unseal", func(w http.ResponseWriter, r *http.Request) {
+		var body map[string]interface{}
+		if err := json.NewDecoder(r.Body).Decode(&body); err != nil {
+			t.Error(err)
+		}
+		submitted = append(submitted, body["key"].(string))
+		json.NewEncoder(w).Encode(api.SealStatusResponse{Initialized: true, Sealed: len(submitted) < 3, T: 3, N: 5, Progress: len(submitted) % 3})
+	})
+
+	server := httptest.NewServer(mux)
+	defer server.Close()
+
+	config := api.DefaultConfig()
+	config.Address = server.URL
+	c, err := api.NewClient(config)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	status, err := unsealPod(context.TODO(), c, []string{"k1", "k2", "k3", "k4", "k5"}, time.Now().Add(time.Minute))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if status.Sealed {
+		t.Fatal("expected Vault to be unsealed")
+	}
+	if len(submitted) != 3 {
+		t.Fatalf("expected 3 keys to be submitted, got %d", len(submitted))
+	}
+}
+
+func TestUnsealPod_unreachable(t *testing.T) {
+	var submitted []string
+	statusRequests := 0
+
+	mux := http.NewServeMux()
+	mux.HandleFunc("/v1/sys/seal-status", func(w http.ResponseWriter, r *http.Request) {
+		// The node is restarting on the first request
+		if statusRequests++; statusRequests == 1 {
+			w.WriteHeader(http.StatusBadGateway)
+			return
+		}
+		json.NewEncoder(w).Encode(api.SealStatusResponse{Initialized: true, Sealed: len(submitted) < 3, T: 3, N: 5})
+	})
+	mux.HandleFunc("/v1/sys/unseal", func(w http.ResponseWriter, r *http.Request) {
+		var body map[string]interface{}
+		if err := json.NewDecoder(r.Body).Decode(&body); err != nil {
+			t.Error(err)
+		}
+		submitted = append(submitted, body["key"].(string))
+		json.NewEncoder(w).Encode(api.SealStatusResponse{Initialized: true, Sealed: len(submitted) < 3, T: 3, N: 5, Progress: len(submitted) % 3})
+	})
+
+	server := httptest.NewServer(mux)
+	defer server.Close()
+
+	config := api.DefaultConfig()
+	config.Address = server.URL
+	config.MaxRetries = 0
+	c, err := api.NewClient(config)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	status, err := unsealPod(context.TODO(), c, []string{"k1", "k2", "k3"}, time.Now().Add(time.Minute))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if status.Sealed {
+		t.Fatal("expected Vault to be unsealed")
+	}
+	if statusRequests < 2 {
+		t.Fatalf("expected the seal status to be polled again, got %d requests", statusRequests)
+	}
+}
+
+// clusterVault is the state of a fake Vault cluster initialized through one
+// of its pods, each pod unsealing with the three keys k1, k2 and k3.
+type clusterVault struct {
+	mu          sync.Mutex
+	initialized bool
+	initPods    []string
+	// unsealed lists the pods in the order they were unsealed
+	unsealed []string
+}
+
+// handlePod registers the endpoints of the pod name of the cluster on vault.
+func (v *clusterVault) handlePod(vault *fakeVault, name string) {
+	progress := 0
+
+	vault.handle("sys/init", func(w http.ResponseWriter, r *http.Request) {
+		v.mu.Lock()
+		defer v.mu.Unlock()
+
+		v.initialized = true
+		v.initPods = append(v.initPods, name)
+		json.NewEncoder(w).Encode(api.InitResponse{Keys: []string{"k1", "k2", "k3"}, RootToken: "root"})
+	})
+	vault.reply("sys/seal-status", func() interface{} {
+		v.mu.Lock()
+		defer v.mu.Unlock()
+
+		return api.SealStatusResponse{Initialized: v.initialized, Sealed: progress < 3, T: 3, N: 3}
+	})
+	vault.handle("sys/unseal", func(w http.ResponseWriter, r *http.Request) {
+		var body map[string]interface{}
+		vault.decode(r, &body)
+
+		v.mu.Lock()
+		defer v.mu.Unlock()
+
+		if key := body["key"].(string); key == fmt.Sprintf("k%d", progress+1) {
+			if progress++; progress == 3 {
+				v.unsealed = append(v.unsealed, name)
+			}
+		}
+		json.NewEncoder(w).Encode(api.SealStatusResponse{Initialized: v.initialized, Sealed: progress < 3, T: 3, N: 3, Progress: progress % 3})
+	})
+}
+
+func TestInitClusterWide(t *testing.T) {
+	ctx := context.TODO()
+
+	cluster := &clusterVault{}
+	vaults := map[string]*fakeVault{}
+	for _, name := range []string{"vault-0", "vault-1", "vault-2"} {
+		vaults[name] = newFakeVault(t)
+		cluster.handlePod(vaults[name], name)
+	}
+
+	// Every running pod is forwarded to, the pending one is not
+	client, forwarded := startKubeVault(t, vaults, "vault-3")
+	client.kubeConn.podSelection = podSelectionName
+	client.kubeConn.podName = "vault-1"
+
+	d := schema.TestResourceDataRaw(t, resourceInit().Schema, map[string]interface{}{
+		argClusterWide: true,
+	})
+
+	diags := initClusterWide(ctx, d, client, &api.InitRequest{SecretShares: 3, SecretThreshold: 3})
+	if len(diags) != 0 {
+		t.Fatalf("unexpected diagnostics %v", diags)
+	}
+
+	if want := []string{"vault-0", "vault-1", "vault-2"}; !reflect.DeepEqual(*forwarded, want) {
+		t.Errorf("expected port forwards to %v, got %v", want, *forwarded)
+	}
+	if want := []string{"vault-1"}; !reflect.DeepEqual(cluster.initPods, want) {
+		t.Errorf("expected %v to be initialized, got %v", want, cluster.initPods)
+	}
+	// The initialized pod is unsealed before the others join it
+	if want := []string{"vault-1", "vault-0", "vault-2"}; !reflect.DeepEqual(cluster.unsealed, want) {
+		t.Errorf("expected the pods to be unsealed in the order %v, got %v", want, cluster.unsealed)
+	}
+
+	if id := d.Id(); id != "vault.vault" {
+		t.Errorf("expected the ID of the service, got %s", id)
+	}
+	if token := d.Get(argRootToken).(string); token != "root" {
+		t.Errorf("unexpected root token %s", token)
+	}
+
+	var pods []interface{}
+	for _, name := range cluster.unsealed {
+		pods = append(pods, map[string]interface{}{argName: name, argInitialized: true, argSealed: false})
+	}
+	if got := d.Get(argPods).([]interface{}); !reflect.DeepEqual(got, pods) {
+		t.Errorf("expected the pods %v, got %v", pods, got)
+	}
+}
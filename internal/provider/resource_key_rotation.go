@@ -0,0 +1,247 @@
+package provider
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"time"
+
+	"github.com/hashicorp/terraform-plugin-sdk/v2/diag"
+	"github.com/hashicorp/terraform-plugin-sdk/v2/helper/schema"
+	"github.com/hashicorp/terraform-plugin-sdk/v2/helper/validation"
+	"github.com/hashicorp/vault/api"
+)
+
+const (
+	pathRotateConfig = "sys/rotate/config"
+
+	argMaxOperations = "max_operations"
+	argInterval      = "interval"
+	argEnabled       = "enabled"
+	argTerm          = "term"
+	argInstallTime   = "install_time"
+	argEncryptions   = "encryptions"
+
+	// The lower bound Vault accepts for max_operations
+	minRotateMaxOperations = 1000000
+	defaultRotateInterval  = "0s"
+)
+
+// defaultRotateMaxOperations is the default of Vault for max_operations, and
+// its upper bound. It overflows an int on 32-bit platforms, so it is never
+// kept in the schema.
+const defaultRotateMaxOperations int64 = 3865470566
+
+func resourceKeyRotation() *schema.Resource {
+	return &schema.Resource{
+		// This description is used by the documentation generator and the language server.
+		Description: "Resource for vault operator rotate. It rotates the encryption key of Vault when created, or when `triggers` change, and manages the automatic rotation configuration. Destroying the resource restores the default configuration. It requires a token.",
+
+		CreateContext: resourceKeyRotationCreate,
+		ReadContext:   resourceKeyRotationRead,
+		UpdateContext: resourceKeyRotationUpdate,
+		DeleteContext: resourceKeyRotationDelete,
+
+		Schema: map[string]*schema.Schema{
+			argVaultConnection: connectionSchema(),
+			argTriggers: {
+				Description: "Arbitrary values which rotate the key when they change, like a date for periodic rotation.",
+				Type:        schema.TypeMap,
+				Optional:    true,
+				ForceNew:    true,
+				Elem:        &schema.Schema{Type: schema.TypeString},
+			},
+			argMaxOperations: {
+				Description:  "The number of encryptions after which the key is rotated automatically. Vault's default, about 3.8 billion, applies when not set.",
+				Type:         schema.TypeInt,
+				Optional:     true,
+				ValidateFunc: validation.IntAtLeast(minRotateMaxOperations),
+			},
+			argInterval: {
+				Description:      "How often the key is rotated automatically, at least `24h`. Disabled when `0s`.",
+				Type:             schema.TypeString,
+				Optional:         true,
+				Default:          defaultRotateInterval,
+				ValidateFunc:     validateDuration,
+				DiffSuppressFunc: suppressEquivalentDuration,
+			},
+			argEnabled: {
+				Description: "Whether the key is rotated automatically.",
+				Type:        schema.TypeBool,
+				Optional:    true,
+				Default:     true,
+			},
+			argTerm: {
+				Description: "The term of the current key, incremented by every rotation.",
+				Type:        schema.TypeInt,
+				Computed:    true,
+			},
+			argInstallTime: {
+				Description: "When the current key was installed, in RFC 3339 format.",
+				Type:        schema.TypeString,
+				Computed:    true,
+			},
+		},
+	}
+}
+
+func resourceKeyRotationCreate(ctx context.Context, d *schema.ResourceData, meta interface{}) diag.Diagnostics {
+	client, diags := meta.(*apiClient).connection(ctx, d)
+	if diags.HasError() {
+		return diags
+	}
+
+	vaultClient, closeVault, diags := client.vault(ctx)
+	if diags.HasError() {
+		return diags
+	}
+	defer closeVault()
+
+	if err := writeRotateConfig(ctx, vaultClient, d.Get(argMaxOperations).(int), d.Get(argInterval).(string), d.Get(argEnabled).(bool)); err != nil {
+		logError("%v", err)
+		return diag.FromErr(err)
+	}
+
+	logInfo("rotating the encryption key")
+
+	if err := vaultClient.Sys().RotateWithContext(ctx); err != nil {
+		logError("failed to rotate the encryption key: %v", err)
+		return diag.FromErr(err)
+	}
+
+	d.SetId(client.url)
+
+	return resourceKeyRotationRead(ctx, d, meta)
+}
+
+func resourceKeyRotationRead(ctx context.Context, d *schema.ResourceData, meta interface{}) diag.Diagnostics {
+	client, diags := meta.(*apiClient).connection(ctx, d)
+	if diags.HasError() {
+		return diags
+	}
+
+	vaultClient, closeVault, diags := client.vault(ctx)
+	if diags.HasError() {
+		return diags
+	}
+	defer closeVault()
+
+	secret, err := vaultClient.Logical().ReadWithContext(ctx, pathRotateConfig)
+	if err != nil {
+		logError("failed to read the key rotation configuration: %v", err)
+		return diag.FromErr(err)
+	}
+	if secret == nil || secret.Data == nil {
+		return diag.Errorf("failed to read the key rotation configuration: empty response")
+	}
+
+	maxOperations, err := parseInt64(secret.Data[argMaxOperations])
+	if err != nil {
+		return diag.FromErr(fmt.Errorf("failed to parse %q: %w", argMaxOperations, err))
+	}
+	// The default is left unset, as when it is not configured
+	if maxOperations == defaultRotateMaxOperations {
+		maxOperations = 0
+	}
+	if int64(int(maxOperations)) != maxOperations {
+		return diag.Errorf("%q of %d does not fit an integer on this platform", argMaxOperations, maxOperations)
+	}
+	if err := d.Set(argMaxOperations, int(maxOperations)); err != nil {
+		return diag.FromErr(err)
+	}
+	if err := d.Set(argInterval, fmt.Sprint(secret.Data[argInterval])); err != nil {
+		return diag.FromErr(err)
+	}
+	if err := d.Set(argEnabled, secret.Data[argEnabled]); err != nil {
+		return diag.FromErr(err)
+	}
+
+	status, err := vaultClient.Sys().KeyStatusWithContext(ctx)
+	if err != nil {
+		logError("failed to read the key status: %v", err)
+		return diag.FromErr(err)
+	}
+
+	if err := d.Set(argTerm, status.Term); err != nil {
+		return diag.FromErr(err)
+	}
+	if err := d.Set(argInstallTime, status.InstallTime.Format(time.RFC3339)); err != nil {
+		return diag.FromErr(err)
+	}
+
+	return diag.Diagnostics{}
+}
+
+func resourceKeyRotationUpdate(ctx context.Context, d *schema.ResourceData, meta interface{}) diag.Diagnostics {
+	client, diags := meta.(*apiClient).connection(ctx, d)
+	if diags.HasError() {
+		return diags
+	}
+
+	vaultClient, closeVault, diags := client.vault(ctx)
+	if diags.HasError() {
+		return diags
+	}
+	defer closeVault()
+
+	if err := writeRotateConfig(ctx, vaultClient, d.Get(argMaxOperations).(int), d.Get(argInterval).(string), d.Get(argEnabled).(bool)); err != nil {
+		logError("%v", err)
+		return diag.FromErr(err)
+	}
+
+	return resourceKeyRotationRead(ctx, d, meta)
+}
+
+func resourceKeyRotationDelete(ctx context.Context, d *schema.ResourceData, meta interface{}) diag.Diagnostics {
+	client, diags := meta.(*apiClient).connection(ctx, d)
+	if diags.HasError() {
+		return diags
+	}
+
+	vaultClient, closeVault, diags := client.vault(ctx)
+	if diags.HasError() {
+		return diags
+	}
+	defer closeVault()
+
+	if err := writeRotateConfig(ctx, vaultClient, 0, defaultRotateInterval, true); err != nil {
+		logError("%v", err)
+		return diag.FromErr(err)
+	}
+
+	return diag.Diagnostics{}
+}
+
+// writeRotateConfig writes the key rotation configuration, restoring the
+// default max_operations when it is 0.
+func writeRotateConfig(ctx context.Context, c *api.Client, maxOperations int, interval string, enabled bool) error {
+	operations := int64(maxOperations)
+	if operations == 0 {
+		operations = defaultRotateMaxOperations
+	}
+
+	_, err := c.Logical().WriteWithContext(ctx, pathRotateConfig, map[string]interface{}{
+		argMaxOperations: operations,
+		argInterval:      interval,
+		argEnabled:       enabled,
+	})
+	if err != nil {
+		return fmt.Errorf("failed to write the key rotation configuration: %w", err)
+	}
+
+	return nil
+}
+
+// parseInt64 converts a number of a Vault response, decoded as a json.Number.
+func parseInt64(v interface{}) (int64, error) {
+	switch n := v.(type) {
+	case json.Number:
+		return n.Int64()
+	case float64:
+		return int64(n), nil
+	case int:
+		return int64(n), nil
+	default:
+		return 0, fmt.Errorf("unexpected value %v", v)
+	}
+}
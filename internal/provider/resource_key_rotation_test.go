@@ -0,0 +1,135 @@
+package provider
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"testing"
+	"time"
+
+	"github.com/hashicorp/terraform-plugin-sdk/v2/helper/resource"
+	"github.com/hashicorp/terraform-plugin-sdk/v2/helper/schema"
+)
+
+var testAccResourceKeyRotationVar = fmt.Sprintf("%[1]s.test", resKeyRotation)
+var testAccDataSourceKeyStatusVar = fmt.Sprintf("data.%[1]s.test", dsKeyStatus)
+
+func testAccResourceKeyRotation(addr, token string) string {
+	return fmt.Sprintf(`
+provider "%[1]s" {
+    vault_addr  = "%[4]s"
+    vault_token = "%[5]s"
+}
+
+resource "%[2]s" "test" {
+	interval = "720h"
+}
+
+data "%[3]s" "test" {
+	depends_on = [%[2]s.test]
+}
+`, provider, resKeyRotation, dsKeyStatus, addr, token)
+}
+
+func TestAccResourceKeyRotation(t *testing.T) {
+	addr := startRaftVault(t, "node-1")
+	token := initRaftVault(t, addr)
+
+	resource.UnitTest(t, resource.TestCase{
+		PreCheck:          func() { testAccPreCheck(t) },
+		ProviderFactories: providerFactories,
+		Steps: []resource.TestStep{
+			{
+				Config: testAccResourceKeyRotation(addr, token),
+				Check: resource.ComposeTestCheckFunc(
+					resource.TestCheckResourceAttr(testAccResourceKeyRotationVar, argTerm, "2"),
+					resource.TestCheckResourceAttr(testAccResourceKeyRotationVar, argInterval, "720h0m0s"),
+					resource.TestCheckResourceAttr(testAccDataSourceKeyStatusVar, argTerm, "2"),
+				),
+			},
+		},
+	})
+}
+
+func TestResourceKeyRotation(t *testing.T) {
+	ctx := context.TODO()
+
+	// The node rotates its key, and keeps the rotation configuration it is
+	// given
+	term := 1
+	config := map[string]interface{}{argMaxOperations: defaultRotateMaxOperations, argInterval: 0, argEnabled: true}
+	vault := newFakeVault(t)
+	vault.handle("sys/rotate", func(w http.ResponseWriter, r *http.Request) {
+		term++
+		w.WriteHeader(http.StatusNoContent)
+	})
+	vault.handle("sys/rotate/config", func(w http.ResponseWriter, r *http.Request) {
+		if r.Method == http.MethodPut || r.Method == http.MethodPost {
+			vault.decode(r, &config)
+			w.WriteHeader(http.StatusNoContent)
+			return
+		}
+		json.NewEncoder(w).Encode(map[string]interface{}{"data": config})
+	})
+	vault.reply("sys/key-status", func() interface{} {
+		return map[string]interface{}{
+			"data": map[string]interface{}{
+				"term":         term,
+				"install_time": time.Date(2022, 1, term, 0, 0, 0, 0, time.UTC),
+				"encryptions":  42,
+			},
+		}
+	})
+	meta := vault.meta()
+
+	d := schema.TestResourceDataRaw(t, resourceKeyRotation().Schema, map[string]interface{}{
+		argMaxOperations: 5000000,
+		argInterval:      "720h",
+	})
+
+	if diags := resourceKeyRotationCreate(ctx, d, meta); diags.HasError() {
+		t.Fatal(diags)
+	}
+
+	if term != 2 {
+		t.Errorf("expected the key to be rotated once, got term %d", term)
+	}
+	if term := d.Get(argTerm).(int); term != 2 {
+		t.Errorf("expected term 2, got %d", term)
+	}
+	if installTime := d.Get(argInstallTime).(string); installTime != "2022-01-02T00:00:00Z" {
+		t.Errorf("unexpected install time %s", installTime)
+	}
+	if maxOperations := d.Get(argMaxOperations).(int); maxOperations != 5000000 {
+		t.Errorf("expected max operations 5000000, got %d", maxOperations)
+	}
+	if interval := d.Get(argInterval).(string); interval != "720h" {
+		t.Errorf("expected interval 720h, got %s", interval)
+	}
+
+	if diags := resourceKeyRotationDelete(ctx, d, meta); diags.HasError() {
+		t.Fatal(diags)
+	}
+	if config[argInterval] != defaultRotateInterval {
+		t.Errorf("expected the default interval, got %v", config[argInterval])
+	}
+	if config[argMaxOperations] != float64(defaultRotateMaxOperations) {
+		t.Errorf("expected the default max operations, got %v", config[argMaxOperations])
+	}
+
+	if diags := resourceKeyRotationRead(ctx, d, meta); diags.HasError() {
+		t.Fatal(diags)
+	}
+	if maxOperations := d.Get(argMaxOperations).(int); maxOperations != 0 {
+		t.Errorf("expected the default max operations to be left unset, got %d", maxOperations)
+	}
+
+	ds := schema.TestResourceDataRaw(t, dataSourceKeyStatus().Schema, map[string]interface{}{})
+	if diags := dataSourceKeyStatusRead(ctx, ds, meta); diags.HasError() {
+		t.Fatal(diags)
+	}
+	if encryptions := ds.Get(argEncryptions).(int); encryptions != 42 {
+		t.Errorf("expected 42 encryptions, got %d", encryptions)
+	}
+}
@@ -0,0 +1,233 @@
+package provider
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"github.com/hashicorp/terraform-plugin-sdk/v2/diag"
+	"github.com/hashicorp/terraform-plugin-sdk/v2/helper/schema"
+	"github.com/hashicorp/terraform-plugin-sdk/v2/helper/validation"
+	"github.com/hashicorp/vault/api"
+)
+
+const (
+	argCleanupDeadServers             = "cleanup_dead_servers"
+	argLastContactThreshold           = "last_contact_threshold"
+	argDeadServerLastContactThreshold = "dead_server_last_contact_threshold"
+	argMaxTrailingLogs                = "max_trailing_logs"
+	argMinQuorum                      = "min_quorum"
+	argServerStabilizationTime        = "server_stabilization_time"
+	argDisableUpgradeMigration        = "disable_upgrade_migration"
+)
+
+// defaultAutopilotConfig is the autopilot configuration of a new cluster,
+// restored when the resource is destroyed.
+var defaultAutopilotConfig = api.AutopilotConfig{
+	LastContactThreshold:           10 * time.Second,
+	DeadServerLastContactThreshold: 24 * time.Hour,
+	MaxTrailingLogs:                1000,
+	ServerStabilizationTime:        10 * time.Second,
+}
+
+func resourceRaftAutopilot() *schema.Resource {
+	return &schema.Resource{
+		// This description is used by the documentation generator and the language server.
+		Description: "Resource for vault operator raft autopilot set-config. Destroying the resource restores the default configuration. It requires a token.",
+
+		CreateContext: resourceRaftAutopilotCreate,
+		ReadContext:   resourceRaftAutopilotRead,
+		UpdateContext: resourceRaftAutopilotUpdate,
+		DeleteContext: resourceRaftAutopilotDelete,
+
+		Schema: map[string]*schema.Schema{
+			argVaultConnection: connectionSchema(),
+			argCleanupDeadServers: {
+				Description: "Remove dead servers from the cluster. Requires `min_quorum`.",
+				Type:        schema.TypeBool,
+				Optional:    true,
+				Default:     defaultAutopilotConfig.CleanupDeadServers,
+			},
+			argLastContactThreshold: {
+				Description:      "How long a server may go without contacting the leader before it is considered unhealthy.",
+				Type:             schema.TypeString,
+				Optional:         true,
+				Default:          defaultAutopilotConfig.LastContactThreshold.String(),
+				ValidateFunc:     validateDuration,
+				DiffSuppressFunc: suppressEquivalentDuration,
+			},
+			argDeadServerLastContactThreshold: {
+				Description:      "How long a server may go without contacting the leader before it is considered dead, and removed with `cleanup_dead_servers`.",
+				Type:             schema.TypeString,
+				Optional:         true,
+				Default:          defaultAutopilotConfig.DeadServerLastContactThreshold.String(),
+				ValidateFunc:     validateDuration,
+				DiffSuppressFunc: suppressEquivalentDuration,
+			},
+			argMaxTrailingLogs: {
+				Description:  "How many log entries a server may trail the leader by before it is considered unhealthy.",
+				Type:         schema.TypeInt,
+				Optional:     true,
+				Default:      int(defaultAutopilotConfig.MaxTrailingLogs),
+				ValidateFunc: validation.IntAtLeast(0),
+			},
+			argMinQuorum: {
+				Description:  "The number of voters below which dead servers are not removed. At least 3 with `cleanup_dead_servers`.",
+				Type:         schema.TypeInt,
+				Optional:     true,
+				Default:      int(defaultAutopilotConfig.MinQuorum),
+				ValidateFunc: validation.IntAtLeast(0),
+			},
+			argServerStabilizationTime: {
+				Description:      "How long a new server must be healthy before it is promoted to a voter.",
+				Type:             schema.TypeString,
+				Optional:         true,
+				Default:          defaultAutopilotConfig.ServerStabilizationTime.String(),
+				ValidateFunc:     validateDuration,
+				DiffSuppressFunc: suppressEquivalentDuration,
+			},
+			argDisableUpgradeMigration: {
+				Description: "Disable the automated upgrade migrations of Vault Enterprise.",
+				Type:        schema.TypeBool,
+				Optional:    true,
+				Default:     defaultAutopilotConfig.DisableUpgradeMigration,
+			},
+		},
+	}
+}
+
+func resourceRaftAutopilotCreate(ctx context.Context, d *schema.ResourceData, meta interface{}) diag.Diagnostics {
+	client, diags := meta.(*apiClient).connection(ctx, d)
+	if diags.HasError() {
+		return diags
+	}
+
+	d.SetId(client.url)
+
+	if diags := resourceRaftAutopilotUpdate(ctx, d, meta); diags.HasError() {
+		d.SetId("")
+		return diags
+	}
+
+	return diag.Diagnostics{}
+}
+
+func resourceRaftAutopilotRead(ctx context.Context, d *schema.ResourceData, meta interface{}) diag.Diagnostics {
+	client, diags := meta.(*apiClient).connection(ctx, d)
+	if diags.HasError() {
+		return diags
+	}
+
+	vaultClient, closeVault, diags := client.vault(ctx)
+	if diags.HasError() {
+		return diags
+	}
+	defer closeVault()
+
+	config, err := vaultClient.Sys().RaftAutopilotConfigurationWithContext(ctx)
+	if err != nil {
+		logError("failed to read the autopilot configuration: %v", err)
+		return diag.FromErr(err)
+	}
+	if config == nil {
+		return diag.Errorf("autopilot is not available, Vault does not use raft storage")
+	}
+
+	values := map[string]interface{}{
+		argCleanupDeadServers:             config.CleanupDeadServers,
+		argLastContactThreshold:           config.LastContactThreshold.String(),
+		argDeadServerLastContactThreshold: config.DeadServerLastContactThreshold.String(),
+		argMaxTrailingLogs:                int(config.MaxTrailingLogs),
+		argMinQuorum:                      int(config.MinQuorum),
+		argServerStabilizationTime:        config.ServerStabilizationTime.String(),
+		argDisableUpgradeMigration:        config.DisableUpgradeMigration,
+	}
+	for k, v := range values {
+		if err := d.Set(k, v); err != nil {
+			return diag.FromErr(err)
+		}
+	}
+
+	return diag.Diagnostics{}
+}
+
+func resourceRaftAutopilotUpdate(ctx context.Context, d *schema.ResourceData, meta interface{}) diag.Diagnostics {
+	client, diags := meta.(*apiClient).connection(ctx, d)
+	if diags.HasError() {
+		return diags
+	}
+
+	vaultClient, closeVault, diags := client.vault(ctx)
+	if diags.HasError() {
+		return diags
+	}
+	defer closeVault()
+
+	// The durations are validated by the schema
+	lastContact, _ := time.ParseDuration(d.Get(argLastContactThreshold).(string))
+	deadServerLastContact, _ := time.ParseDuration(d.Get(argDeadServerLastContactThreshold).(string))
+	stabilization, _ := time.ParseDuration(d.Get(argServerStabilizationTime).(string))
+
+	config := api.AutopilotConfig{
+		CleanupDeadServers:             d.Get(argCleanupDeadServers).(bool),
+		LastContactThreshold:           lastContact,
+		DeadServerLastContactThreshold: deadServerLastContact,
+		MaxTrailingLogs:                uint64(d.Get(argMaxTrailingLogs).(int)),
+		MinQuorum:                      uint(d.Get(argMinQuorum).(int)),
+		ServerStabilizationTime:        stabilization,
+		DisableUpgradeMigration:        d.Get(argDisableUpgradeMigration).(bool),
+	}
+
+	logDebug("setting the autopilot configuration %+v", config)
+
+	if err := vaultClient.Sys().PutRaftAutopilotConfigurationWithContext(ctx, &config); err != nil {
+		logError("failed to set the autopilot configuration: %v", err)
+		return diag.FromErr(err)
+	}
+
+	return resourceRaftAutopilotRead(ctx, d, meta)
+}
+
+func resourceRaftAutopilotDelete(ctx context.Context, d *schema.ResourceData, meta interface{}) diag.Diagnostics {
+	client, diags := meta.(*apiClient).connection(ctx, d)
+	if diags.HasError() {
+		return diags
+	}
+
+	vaultClient, closeVault, diags := client.vault(ctx)
+	if diags.HasError() {
+		return diags
+	}
+	defer closeVault()
+
+	config := defaultAutopilotConfig
+	if err := vaultClient.Sys().PutRaftAutopilotConfigurationWithContext(ctx, &config); err != nil {
+		logError("failed to reset the autopilot configuration: %v", err)
+		return diag.FromErr(err)
+	}
+
+	return diag.Diagnostics{}
+}
+
+func validateDuration(v interface{}, k string) ([]string, []error) {
+	if _, err := time.ParseDuration(v.(string)); err != nil {
+		return nil, []error{fmt.Errorf("%q is not a valid duration: %w", k, err)}
+	}
+
+	return nil, nil
+}
+
+// suppressEquivalentDuration ignores the difference between durations
+// written differently, like 24h and 24h0m0s.
+func suppressEquivalentDuration(k, old, new string, d *schema.ResourceData) bool {
+	o, err := time.ParseDuration(old)
+	if err != nil {
+		return false
+	}
+	n, err := time.ParseDuration(new)
+	if err != nil {
+		return false
+	}
+
+	return o == n
+}
@@ -0,0 +1,107 @@
+package provider
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"testing"
+	"time"
+
+	"github.com/hashicorp/terraform-plugin-sdk/v2/helper/resource"
+	"github.com/hashicorp/terraform-plugin-sdk/v2/helper/schema"
+)
+
+var testAccResourceRaftAutopilotVar = fmt.Sprintf("%[1]s.test", resRaftAutopilot)
+var testAccDataSourceRaftAutopilotStateVar = fmt.Sprintf("data.%[1]s.test", dsAutopilotState)
+
+func testAccResourceRaftAutopilot(addr, token string) string {
+	return fmt.Sprintf(`
+provider "%[1]s" {
+    vault_addr  = "%[4]s"
+    vault_token = "%[5]s"
+}
+
+resource "%[2]s" "test" {
+	cleanup_dead_servers               = true
+	dead_server_last_contact_threshold = "1h"
+	min_quorum                         = 3
+}
+
+data "%[3]s" "test" {
+	depends_on = [%[2]s.test]
+}
+`, provider, resRaftAutopilot, dsAutopilotState, addr, token)
+}
+
+func TestAccResourceRaftAutopilot(t *testing.T) {
+	addr := startRaftVault(t, "node-1")
+	token := initRaftVault(t, addr)
+
+	resource.UnitTest(t, resource.TestCase{
+		PreCheck:          func() { testAccPreCheck(t) },
+		ProviderFactories: providerFactories,
+		Steps: []resource.TestStep{
+			{
+				Config: testAccResourceRaftAutopilot(addr, token),
+				Check: resource.ComposeTestCheckFunc(
+					resource.TestCheckResourceAttr(testAccResourceRaftAutopilotVar, argCleanupDeadServers, "true"),
+					resource.TestCheckResourceAttr(testAccResourceRaftAutopilotVar, argDeadServerLastContactThreshold, "1h0m0s"),
+					resource.TestCheckResourceAttr(testAccResourceRaftAutopilotVar, argMinQuorum, "3"),
+					resource.TestCheckResourceAttr(testAccDataSourceRaftAutopilotStateVar, argLeader, "node-1"),
+					resource.TestCheckResourceAttr(testAccDataSourceRaftAutopilotStateVar, "servers.#", "1"),
+					resource.TestCheckResourceAttr(testAccDataSourceRaftAutopilotStateVar, "servers.0.id", "node-1"),
+				),
+			},
+		},
+	})
+}
+
+func TestResourceRaftAutopilot(t *testing.T) {
+	ctx := context.TODO()
+
+	// The node keeps the autopilot configuration it is given
+	config := defaultAutopilotConfig
+	vault := newFakeVault(t)
+	vault.handle("sys/storage/raft/autopilot/configuration", func(w http.ResponseWriter, r *http.Request) {
+		if r.Method == http.MethodPost {
+			vault.decode(r, &config)
+			w.WriteHeader(http.StatusNoContent)
+			return
+		}
+		json.NewEncoder(w).Encode(map[string]interface{}{"data": &config})
+	})
+	meta := vault.meta()
+
+	d := schema.TestResourceDataRaw(t, resourceRaftAutopilot().Schema, map[string]interface{}{
+		argCleanupDeadServers:             true,
+		argDeadServerLastContactThreshold: "1h",
+		argMinQuorum:                      3,
+	})
+
+	if diags := resourceRaftAutopilotCreate(ctx, d, meta); diags.HasError() {
+		t.Fatal(diags)
+	}
+
+	expected := defaultAutopilotConfig
+	expected.CleanupDeadServers = true
+	expected.DeadServerLastContactThreshold = time.Hour
+	expected.MinQuorum = 3
+	if config != expected {
+		t.Errorf("expected %+v, got %+v", expected, config)
+	}
+
+	if threshold := d.Get(argDeadServerLastContactThreshold).(string); threshold != "1h0m0s" {
+		t.Errorf("expected 1h0m0s, got %s", threshold)
+	}
+	if !suppressEquivalentDuration(argDeadServerLastContactThreshold, "1h0m0s", "1h", d) {
+		t.Error("expected 1h0m0s and 1h to be equivalent")
+	}
+
+	if diags := resourceRaftAutopilotDelete(ctx, d, meta); diags.HasError() {
+		t.Fatal(diags)
+	}
+	if config != defaultAutopilotConfig {
+		t.Errorf("expected the default configuration, got %+v", config)
+	}
+}
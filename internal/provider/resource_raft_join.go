@@ -0,0 +1,188 @@
+package provider
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/hashicorp/terraform-plugin-sdk/v2/diag"
+	"github.com/hashicorp/terraform-plugin-sdk/v2/helper/schema"
+	"github.com/hashicorp/vault/api"
+)
+
+const (
+	argNodeID           = "node_id"
+	argLeaderAPIAddr    = "leader_api_addr"
+	argLeaderCACert     = "leader_ca_cert"
+	argLeaderClientCert = "leader_client_cert"
+	argLeaderClientKey  = "leader_client_key"
+	argRetry            = "retry"
+	argNonVoter         = "non_voter"
+	argVoter            = "voter"
+	argAddress          = "address"
+)
+
+func resourceRaftJoin() *schema.Resource {
+	return &schema.Resource{
+		// This description is used by the documentation generator and the language server.
+		Description: "Resource for vault operator raft join. It joins the target node to the raft cluster of the leader, and detects when the node is no longer a peer of the cluster. Destroying the resource does not remove the node from the cluster.",
+
+		CreateContext: resourceRaftJoinCreate,
+		ReadContext:   resourceRaftJoinRead,
+		UpdateContext: resourceRaftJoinUpdate,
+		DeleteContext: resourceRaftJoinDelete,
+
+		Schema: map[string]*schema.Schema{
+			argVaultConnection: connectionSchema(),
+			argNodeID: {
+				Description: "The raft node ID of the target node, the `node_id` of its raft storage, used to find it among the peers of the cluster.",
+				Type:        schema.TypeString,
+				Required:    true,
+				ForceNew:    true,
+			},
+			argLeaderAPIAddr: {
+				Description: "The address of the leader node, as reachable from the target node.",
+				Type:        schema.TypeString,
+				Required:    true,
+				ForceNew:    true,
+			},
+			argLeaderCACert: {
+				Description: "PEM-encoded CA certificate the target node verifies the leader with.",
+				Type:        schema.TypeString,
+				Optional:    true,
+				ForceNew:    true,
+			},
+			argLeaderClientCert: {
+				Description: "PEM-encoded client certificate the target node authenticates to the leader with.",
+				Type:        schema.TypeString,
+				Optional:    true,
+				ForceNew:    true,
+			},
+			argLeaderClientKey: {
+				Description: "PEM-encoded key of `leader_client_cert`.",
+				Type:        schema.TypeString,
+				Optional:    true,
+				Sensitive:   true,
+				ForceNew:    true,
+			},
+			argRetry: {
+				Description: "Keep retrying to join the leader in the background, when it cannot be reached yet.",
+				Type:        schema.TypeBool,
+				Optional:    true,
+				ForceNew:    true,
+			},
+			argNonVoter: {
+				Description: "Join as a non-voting peer.",
+				Type:        schema.TypeBool,
+				Optional:    true,
+				ForceNew:    true,
+			},
+			argVoter: {
+				Description: "Whether the node is a voting peer of the cluster.",
+				Type:        schema.TypeBool,
+				Computed:    true,
+			},
+			argAddress: {
+				Description: "The cluster address of the node.",
+				Type:        schema.TypeString,
+				Computed:    true,
+			},
+		},
+	}
+}
+
+func resourceRaftJoinCreate(ctx context.Context, d *schema.ResourceData, meta interface{}) diag.Diagnostics {
+	client, diags := meta.(*apiClient).connection(ctx, d)
+	if diags.HasError() {
+		return diags
+	}
+
+	vaultClient, closeVault, diags := client.vault(ctx)
+	if diags.HasError() {
+		return diags
+	}
+	defer closeVault()
+
+	req := api.RaftJoinRequest{
+		LeaderAPIAddr:    d.Get(argLeaderAPIAddr).(string),
+		LeaderCACert:     d.Get(argLeaderCACert).(string),
+		LeaderClientCert: d.Get(argLeaderClientCert).(string),
+		LeaderClientKey:  d.Get(argLeaderClientKey).(string),
+		Retry:            d.Get(argRetry).(bool),
+		NonVoter:         d.Get(argNonVoter).(bool),
+	}
+
+	logDebug("joining %s to the raft cluster of %s", d.Get(argNodeID), req.LeaderAPIAddr)
+
+	res, err := vaultClient.Sys().RaftJoinWithContext(ctx, &req)
+	if err != nil {
+		logError("failed to join the raft cluster: %v", err)
+		return diag.FromErr(err)
+	}
+
+	if !res.Joined {
+		return diag.Errorf("node %s did not join the raft cluster of %s", d.Get(argNodeID), req.LeaderAPIAddr)
+	}
+
+	d.SetId(d.Get(argNodeID).(string))
+
+	return resourceRaftJoinRead(ctx, d, meta)
+}
+
+func resourceRaftJoinRead(ctx context.Context, d *schema.ResourceData, meta interface{}) diag.Diagnostics {
+	client, diags := meta.(*apiClient).connection(ctx, d)
+	if diags.HasError() {
+		return diags
+	}
+
+	vaultClient, closeVault, diags := client.vault(ctx)
+	if diags.HasError() {
+		return diags
+	}
+	defer closeVault()
+
+	health, err := vaultClient.Sys().HealthWithContext(ctx)
+	if err != nil {
+		logError("failed to read Vault health: %v", err)
+		return diag.FromErr(err)
+	}
+
+	// With Shamir seals, a node only completes its join once unsealed, and a
+	// sealed node cannot tell its peers
+	if health.Sealed {
+		logInfo("node %s is sealed, its raft membership cannot be checked", d.Id())
+		return nil
+	}
+
+	servers, err := raftConfiguration(ctx, vaultClient)
+	if err != nil {
+		logError("%v", err)
+		return diag.FromErr(fmt.Errorf("%w, check that a token is configured", err))
+	}
+
+	server := findRaftServer(servers, d.Id())
+	if server == nil {
+		logInfo("node %s is no longer a peer of the raft cluster", d.Id())
+		d.SetId("")
+		return nil
+	}
+
+	if err := d.Set(argVoter, server.Voter); err != nil {
+		return diag.FromErr(err)
+	}
+	if err := d.Set(argAddress, server.Address); err != nil {
+		return diag.FromErr(err)
+	}
+
+	return diag.Diagnostics{}
+}
+
+func resourceRaftJoinUpdate(ctx context.Context, d *schema.ResourceData, meta interface{}) diag.Diagnostics {
+	// Only vault_connection can change in place, which does not move the node
+	return resourceRaftJoinRead(ctx, d, meta)
+}
+
+func resourceRaftJoinDelete(ctx context.Context, d *schema.ResourceData, meta interface{}) diag.Diagnostics {
+	// Removing the peer is left to the operator, so destroying the
+	// resource never shrinks the cluster by accident
+	return diag.Diagnostics{}
+}
@@ -0,0 +1,109 @@
+package provider
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"testing"
+
+	"github.com/hashicorp/terraform-plugin-sdk/v2/helper/resource"
+	"github.com/hashicorp/terraform-plugin-sdk/v2/helper/schema"
+	"github.com/hashicorp/vault/api"
+)
+
+var testAccResourceRaftJoinVar = fmt.Sprintf("%[1]s.test", resRaftJoin)
+
+func testAccResourceRaftJoin(leaderAddr, token, followerAddr string) string {
+	return fmt.Sprintf(`
+provider "%[1]s" {
+    vault_addr  = "%[3]s"
+    vault_token = "%[4]s"
+}
+
+resource "%[2]s" "test" {
+	vault_connection {
+		address = "%[5]s"
+	}
+
+	node_id         = "node-2"
+	leader_api_addr = "%[3]s"
+}
+`, provider, resRaftJoin, leaderAddr, token, followerAddr)
+}
+
+func TestAccResourceRaftJoin(t *testing.T) {
+	leaderAddr := startRaftVault(t, "node-1")
+	followerAddr := startRaftVault(t, "node-2")
+	token := initRaftVault(t, leaderAddr)
+
+	resource.UnitTest(t, resource.TestCase{
+		PreCheck:          func() { testAccPreCheck(t) },
+		ProviderFactories: providerFactories,
+		Steps: []resource.TestStep{
+			{
+				Config: testAccResourceRaftJoin(leaderAddr, token, followerAddr),
+				Check: resource.ComposeTestCheckFunc(
+					resource.TestCheckResourceAttr(testAccResourceRaftJoinVar, "id", "node-2"),
+					resource.TestCheckResourceAttr(testAccResourceRaftJoinVar, argNodeID, "node-2"),
+				),
+			},
+		},
+	})
+}
+
+func TestResourceRaftJoin(t *testing.T) {
+	ctx := context.TODO()
+	servers := []raftServer{
+		{NodeID: "node-1", Address: "10.0.0.1:8201", Leader: true, Voter: true},
+		{NodeID: "node-2", Address: "10.0.0.2:8201", Voter: true},
+	}
+
+	// The node is unsealed, and joins the raft cluster of servers
+	var joined api.RaftJoinRequest
+	vault := newFakeVault(t)
+	vault.handle("sys/storage/raft/join", func(w http.ResponseWriter, r *http.Request) {
+		vault.decode(r, &joined)
+		json.NewEncoder(w).Encode(api.RaftJoinResponse{Joined: true})
+	})
+	vault.reply("sys/health", func() interface{} {
+		return api.HealthResponse{Initialized: true, Standby: true}
+	})
+	vault.reply("sys/storage/raft/configuration", func() interface{} {
+		return raftConfigurationReply(servers)
+	})
+	meta := vault.meta()
+
+	d := schema.TestResourceDataRaw(t, resourceRaftJoin().Schema, map[string]interface{}{
+		argNodeID:        "node-2",
+		argLeaderAPIAddr: "https://10.0.0.1:8200",
+		argNonVoter:      true,
+	})
+
+	if diags := resourceRaftJoinCreate(ctx, d, meta); diags.HasError() {
+		t.Fatal(diags)
+	}
+
+	if joined.LeaderAPIAddr != "https://10.0.0.1:8200" || !joined.NonVoter {
+		t.Errorf("unexpected join request %+v", joined)
+	}
+	if d.Id() != "node-2" {
+		t.Errorf("expected id node-2, got %q", d.Id())
+	}
+	if address := d.Get(argAddress).(string); address != "10.0.0.2:8201" {
+		t.Errorf("expected address 10.0.0.2:8201, got %q", address)
+	}
+	if !d.Get(argVoter).(bool) {
+		t.Error("expected the node to be a voter")
+	}
+
+	// The node is removed from the cluster out of band
+	servers = servers[:1]
+
+	if diags := resourceRaftJoinRead(ctx, d, meta); diags.HasError() {
+		t.Fatal(diags)
+	}
+	if d.Id() != "" {
+		t.Errorf("expected the resource to be gone, got id %q", d.Id())
+	}
+}
@@ -0,0 +1,167 @@
+package provider
+
+import (
+	"context"
+	"fmt"
+	"strings"
+
+	"github.com/hashicorp/terraform-plugin-sdk/v2/diag"
+	"github.com/hashicorp/terraform-plugin-sdk/v2/helper/schema"
+)
+
+const (
+	argNodeIDs = "node_ids"
+)
+
+func resourceRaftPeers() *schema.Resource {
+	return &schema.Resource{
+		// This description is used by the documentation generator and the language server.
+		Description: "Resource for vault operator raft remove-peer. It declares the peers of the raft cluster, and removes the other peers on apply. It refuses to remove the leader, or so many voters that the remaining ones would be below the quorum of the cluster. Destroying the resource does not change the cluster. It requires a token.",
+
+		CreateContext: resourceRaftPeersCreate,
+		ReadContext:   resourceRaftPeersRead,
+		UpdateContext: resourceRaftPeersUpdate,
+		DeleteContext: resourceRaftPeersDelete,
+
+		Schema: map[string]*schema.Schema{
+			argVaultConnection: connectionSchema(),
+			argNodeIDs: {
+				Description: "The raft node IDs of the peers to keep. Nodes are added to the cluster with `vaultoperator_raft_join`.",
+				Type:        schema.TypeSet,
+				Required:    true,
+				MinItems:    1,
+				Elem:        &schema.Schema{Type: schema.TypeString},
+			},
+			argServers: raftServersSchema(),
+		},
+	}
+}
+
+func resourceRaftPeersCreate(ctx context.Context, d *schema.ResourceData, meta interface{}) diag.Diagnostics {
+	diags := resourceRaftPeersApply(ctx, d, meta)
+	if diags.HasError() {
+		return diags
+	}
+
+	return append(diags, resourceRaftPeersRead(ctx, d, meta)...)
+}
+
+func resourceRaftPeersRead(ctx context.Context, d *schema.ResourceData, meta interface{}) diag.Diagnostics {
+	client, diags := meta.(*apiClient).connection(ctx, d)
+	if diags.HasError() {
+		return diags
+	}
+
+	vaultClient, closeVault, diags := client.vault(ctx)
+	if diags.HasError() {
+		return diags
+	}
+	defer closeVault()
+
+	servers, err := raftConfiguration(ctx, vaultClient)
+	if err != nil {
+		logError("%v", err)
+		return diag.FromErr(err)
+	}
+
+	// Stale peers show up as drift. Declared peers which have not joined yet
+	// are kept, as removing peers cannot add them.
+	nodeIDs := schema.NewSet(schema.HashString, nil)
+	for _, s := range servers {
+		nodeIDs.Add(s.NodeID)
+	}
+	for _, id := range d.Get(argNodeIDs).(*schema.Set).List() {
+		nodeIDs.Add(id)
+	}
+
+	if err := d.Set(argNodeIDs, nodeIDs); err != nil {
+		return diag.FromErr(err)
+	}
+	if err := d.Set(argServers, flattenRaftServers(servers)); err != nil {
+		return diag.FromErr(err)
+	}
+
+	return diag.Diagnostics{}
+}
+
+func resourceRaftPeersUpdate(ctx context.Context, d *schema.ResourceData, meta interface{}) diag.Diagnostics {
+	diags := resourceRaftPeersApply(ctx, d, meta)
+	if diags.HasError() {
+		return diags
+	}
+
+	return append(diags, resourceRaftPeersRead(ctx, d, meta)...)
+}
+
+func resourceRaftPeersDelete(ctx context.Context, d *schema.ResourceData, meta interface{}) diag.Diagnostics {
+	// Forgetting the declared peers leaves the cluster as it is
+	return diag.Diagnostics{}
+}
+
+// resourceRaftPeersApply removes the peers which are not declared, once it
+// made sure the cluster keeps its leader and its quorum.
+func resourceRaftPeersApply(ctx context.Context, d *schema.ResourceData, meta interface{}) diag.Diagnostics {
+	client, diags := meta.(*apiClient).connection(ctx, d)
+	if diags.HasError() {
+		return diags
+	}
+
+	vaultClient, closeVault, diags := client.vault(ctx)
+	if diags.HasError() {
+		return diags
+	}
+	defer closeVault()
+
+	d.SetId(client.url)
+
+	servers, err := raftConfiguration(ctx, vaultClient)
+	if err != nil {
+		logError("%v", err)
+		return diag.FromErr(err)
+	}
+
+	declared := d.Get(argNodeIDs).(*schema.Set)
+
+	var stale []string
+	voters, staleVoters := 0, 0
+	for _, s := range servers {
+		if s.Voter {
+			voters++
+		}
+		if declared.Contains(s.NodeID) {
+			continue
+		}
+		if s.Leader {
+			return diag.Errorf("refusing to remove raft peer %s, it is the leader of the cluster", s.NodeID)
+		}
+		if s.Voter {
+			staleVoters++
+		}
+		stale = append(stale, s.NodeID)
+	}
+
+	if quorum := voters/2 + 1; voters-staleVoters < quorum {
+		return diag.Errorf("refusing to remove raft peers %s, %d of the %d voters would remain, below the quorum of %d",
+			strings.Join(stale, ", "), voters-staleVoters, voters, quorum)
+	}
+
+	for _, id := range declared.List() {
+		if findRaftServer(servers, id.(string)) == nil {
+			diags = append(diags, diag.Diagnostic{
+				Severity: diag.Warning,
+				Summary:  fmt.Sprintf("raft peer %s is not part of the cluster", id),
+			})
+		}
+	}
+
+	for _, id := range stale {
+		logInfo("removing raft peer %s", id)
+
+		if err := removeRaftPeer(ctx, vaultClient, id); err != nil {
+			logError("%v", err)
+			return append(diags, diag.FromErr(err)...)
+		}
+	}
+
+	return diags
+}
@@ -0,0 +1,143 @@
+package provider
+
+import (
+	"context"
+	"net/http"
+	"reflect"
+	"sort"
+	"testing"
+
+	"github.com/hashicorp/terraform-plugin-sdk/v2/helper/schema"
+)
+
+// handleRaftPeers registers the endpoints of a Vault node listing servers
+// as the peers of its raft cluster on vault, and removing them on
+// sys/storage/raft/remove-peer. It returns the node IDs of the removed peers.
+func handleRaftPeers(vault *fakeVault, servers []raftServer) *[]string {
+	var removed []string
+
+	vault.reply("sys/storage/raft/configuration", func() interface{} {
+		return raftConfigurationReply(servers)
+	})
+	vault.handle("sys/storage/raft/remove-peer", func(w http.ResponseWriter, r *http.Request) {
+		var body map[string]string
+		vault.decode(r, &body)
+		for i, s := range servers {
+			if s.NodeID == body["server_id"] {
+				servers = append(servers[:i], servers[i+1:]...)
+				break
+			}
+		}
+		removed = append(removed, body["server_id"])
+		w.WriteHeader(http.StatusNoContent)
+	})
+
+	return &removed
+}
+
+func TestResourceRaftPeers(t *testing.T) {
+	ctx := context.TODO()
+
+	cases := []struct {
+		name    string
+		servers []raftServer
+		nodeIDs []interface{}
+		removed []string
+		err     bool
+	}{
+		{
+			name: "removes stale peers",
+			servers: []raftServer{
+				{NodeID: "node-1", Leader: true, Voter: true},
+				{NodeID: "node-2", Voter: true},
+				{NodeID: "node-3", Voter: true},
+				{NodeID: "old-node", Voter: true},
+				{NodeID: "old-non-voter"},
+			},
+			nodeIDs: []interface{}{"node-1", "node-2", "node-3"},
+			removed: []string{"old-node", "old-non-voter"},
+		},
+		{
+			name: "keeps the cluster as is",
+			servers: []raftServer{
+				{NodeID: "node-1", Leader: true, Voter: true},
+				{NodeID: "node-2", Voter: true},
+			},
+			nodeIDs: []interface{}{"node-1", "node-2", "node-3"},
+		},
+		{
+			name: "refuses to drop below quorum",
+			servers: []raftServer{
+				{NodeID: "node-1", Leader: true, Voter: true},
+				{NodeID: "node-2", Voter: true},
+				{NodeID: "node-3", Voter: true},
+			},
+			nodeIDs: []interface{}{"node-1"},
+			err:     true,
+		},
+		{
+			name: "refuses to remove the leader",
+			servers: []raftServer{
+				{NodeID: "node-1", Leader: true, Voter: true},
+				{NodeID: "node-2", Voter: true},
+				{NodeID: "node-3", Voter: true},
+			},
+			nodeIDs: []interface{}{"node-2", "node-3"},
+			err:     true,
+		},
+	}
+
+	for _, c := range cases {
+		t.Run(c.name, func(t *testing.T) {
+			vault := newFakeVault(t)
+			removed := handleRaftPeers(vault, c.servers)
+			meta := vault.meta()
+
+			d := schema.TestResourceDataRaw(t, resourceRaftPeers().Schema, map[string]interface{}{
+				argNodeIDs: c.nodeIDs,
+			})
+
+			diags := resourceRaftPeersCreate(ctx, d, meta)
+			if diags.HasError() != c.err {
+				t.Fatalf("unexpected diagnostics %v", diags)
+			}
+
+			sort.Strings(*removed)
+			if !reflect.DeepEqual(*removed, c.removed) {
+				t.Errorf("expected %v to be removed, got %v", c.removed, *removed)
+			}
+		})
+	}
+}
+
+func TestResourceRaftPeers_drift(t *testing.T) {
+	ctx := context.TODO()
+	vault := newFakeVault(t)
+	handleRaftPeers(vault, []raftServer{
+		{NodeID: "node-1", Leader: true, Voter: true},
+		{NodeID: "node-2", Voter: true},
+		{NodeID: "old-node", Voter: true},
+	})
+	meta := vault.meta()
+
+	d := schema.TestResourceDataRaw(t, resourceRaftPeers().Schema, map[string]interface{}{
+		argNodeIDs: []interface{}{"node-1", "node-2", "node-3"},
+	})
+
+	if diags := resourceRaftPeersRead(ctx, d, meta); diags.HasError() {
+		t.Fatal(diags)
+	}
+
+	var nodeIDs []string
+	for _, id := range d.Get(argNodeIDs).(*schema.Set).List() {
+		nodeIDs = append(nodeIDs, id.(string))
+	}
+	sort.Strings(nodeIDs)
+
+	if expected := []string{"node-1", "node-2", "node-3", "old-node"}; !reflect.DeepEqual(nodeIDs, expected) {
+		t.Errorf("expected %v, got %v", expected, nodeIDs)
+	}
+	if n := d.Get(argServers + ".#").(int); n != 3 {
+		t.Errorf("expected 3 servers, got %d", n)
+	}
+}
@@ -0,0 +1,223 @@
+package provider
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"github.com/hashicorp/terraform-plugin-sdk/v2/diag"
+	"github.com/hashicorp/terraform-plugin-sdk/v2/helper/schema"
+	"github.com/hashicorp/vault/api"
+)
+
+const (
+	argSnapshotPath = "path"
+	argTriggers     = "triggers"
+	argSize         = "size"
+	argSHA256       = "sha256"
+	argTimestamp    = "timestamp"
+)
+
+func resourceRaftSnapshot() *schema.Resource {
+	return &schema.Resource{
+		// This description is used by the documentation generator and the language server.
+		Description: "Resource for vault operator raft snapshot save. It takes a snapshot of the raft cluster to a local file or an S3-compatible bucket when created, or when `triggers` change. Destroying the resource keeps the snapshot. It requires a token.",
+
+		CreateContext: resourceRaftSnapshotCreate,
+		ReadContext:   resourceRaftSnapshotRead,
+		UpdateContext: resourceRaftSnapshotUpdate,
+		DeleteContext: resourceRaftSnapshotDelete,
+
+		Schema: map[string]*schema.Schema{
+			argVaultConnection: connectionSchema(),
+			argSnapshotPath: {
+				Description:  "Local file to write the snapshot to. It is replaced once the snapshot is complete.",
+				Type:         schema.TypeString,
+				Optional:     true,
+				ForceNew:     true,
+				ExactlyOneOf: []string{argSnapshotPath, argS3},
+			},
+			argS3: s3Schema(),
+			argTriggers: {
+				Description: "Arbitrary values which take a new snapshot when they change, like the Vault version about to be deployed.",
+				Type:        schema.TypeMap,
+				Optional:    true,
+				ForceNew:    true,
+				Elem:        &schema.Schema{Type: schema.TypeString},
+			},
+			argSize: {
+				Description: "The size of the snapshot in bytes.",
+				Type:        schema.TypeInt,
+				Computed:    true,
+			},
+			argSHA256: {
+				Description: "The hex encoded SHA-256 of the snapshot.",
+				Type:        schema.TypeString,
+				Computed:    true,
+			},
+			argTimestamp: {
+				Description: "When the snapshot was taken, in RFC 3339 format.",
+				Type:        schema.TypeString,
+				Computed:    true,
+			},
+			argS3Key: {
+				Description: "The object key of the snapshot, when uploaded to `s3`.",
+				Type:        schema.TypeString,
+				Computed:    true,
+			},
+		},
+	}
+}
+
+func resourceRaftSnapshotCreate(ctx context.Context, d *schema.ResourceData, meta interface{}) diag.Diagnostics {
+	client, diags := meta.(*apiClient).connection(ctx, d)
+	if diags.HasError() {
+		return diags
+	}
+
+	vaultClient, closeVault, diags := client.vault(ctx)
+	if diags.HasError() {
+		return diags
+	}
+	defer closeVault()
+
+	timestamp := time.Now().UTC()
+
+	var size int64
+	var sum string
+	var bucket *s3Bucket
+	var err error
+	if spec, ok := d.Get(argS3).([]interface{}); ok && len(spec) > 0 && spec[0] != nil {
+		if bucket, err = expandS3(spec[0].(map[string]interface{})); err == nil {
+			size, sum, err = snapshotToS3(ctx, d, vaultClient, bucket, timestamp)
+		}
+	} else {
+		size, sum, err = snapshotToFile(ctx, vaultClient, d.Get(argSnapshotPath).(string))
+	}
+	if err != nil {
+		logError("%v", err)
+		return diag.FromErr(err)
+	}
+
+	d.SetId(sum)
+
+	if err := d.Set(argSize, size); err != nil {
+		return diag.FromErr(err)
+	}
+	if err := d.Set(argSHA256, sum); err != nil {
+		return diag.FromErr(err)
+	}
+	if err := d.Set(argTimestamp, timestamp.Format(time.RFC3339)); err != nil {
+		return diag.FromErr(err)
+	}
+
+	// The snapshot is taken, failing to delete older ones must not lose it
+	if bucket != nil {
+		if _, err := bucket.prune(ctx); err != nil {
+			logError("%v", err)
+			return diag.Diagnostics{{
+				Severity: diag.Warning,
+				Summary:  "Failed to delete the raft snapshots beyond the retention",
+				Detail:   err.Error(),
+			}}
+		}
+	}
+
+	return diag.Diagnostics{}
+}
+
+// snapshotToFile writes a snapshot to the file at path.
+func snapshotToFile(ctx context.Context, c *api.Client, path string) (int64, string, error) {
+	if strings.Contains(path, "~") {
+		homeDir, err := homeDir()
+		if err != nil {
+			return 0, "", err
+		}
+		path = strings.Replace(path, "~", homeDir, -1)
+	}
+
+	// Write to a temporary file next to path, so that a failed snapshot never
+	// replaces a previous one
+	f, err := os.CreateTemp(filepath.Dir(path), "."+filepath.Base(path)+"-*")
+	if err != nil {
+		return 0, "", fmt.Errorf("failed to create the snapshot file: %w", err)
+	}
+	defer os.Remove(f.Name())
+
+	logDebug("taking a raft snapshot to %s", path)
+
+	size, sum, err := writeRaftSnapshot(ctx, c, f)
+	if err != nil {
+		f.Close()
+		return 0, "", err
+	}
+
+	if err := f.Close(); err != nil {
+		return 0, "", fmt.Errorf("failed to write the snapshot file: %w", err)
+	}
+	if err := os.Rename(f.Name(), path); err != nil {
+		return 0, "", fmt.Errorf("failed to write the snapshot file: %w", err)
+	}
+
+	logInfo("took a raft snapshot of %d bytes to %s", size, path)
+
+	return size, sum, nil
+}
+
+// snapshotToS3 streams a snapshot to bucket.
+func snapshotToS3(ctx context.Context, d *schema.ResourceData, c *api.Client, bucket *s3Bucket, timestamp time.Time) (int64, string, error) {
+	key := bucket.snapshotKey(timestamp)
+
+	logDebug("taking a raft snapshot to s3://%s/%s", bucket.bucket, key)
+
+	// The pipe is only closed once the snapshot is verified complete, so an
+	// incomplete snapshot aborts the upload instead of completing it
+	r, w := io.Pipe()
+
+	var size int64
+	var sum string
+	snapshotErr := make(chan error, 1)
+	go func() {
+		var err error
+		size, sum, err = writeRaftSnapshot(ctx, c, w)
+		w.CloseWithError(err)
+		snapshotErr <- err
+	}()
+
+	err := bucket.upload(ctx, key, r)
+	r.CloseWithError(err)
+	if serr := <-snapshotErr; err == nil {
+		err = serr
+	}
+	if err != nil {
+		return 0, "", err
+	}
+
+	logInfo("took a raft snapshot of %d bytes to s3://%s/%s", size, bucket.bucket, key)
+
+	if err := d.Set(argS3Key, key); err != nil {
+		return 0, "", err
+	}
+
+	return size, sum, nil
+}
+
+func resourceRaftSnapshotRead(ctx context.Context, d *schema.ResourceData, meta interface{}) diag.Diagnostics {
+	// A snapshot is taken once, there is nothing to refresh
+	return diag.Diagnostics{}
+}
+
+func resourceRaftSnapshotUpdate(ctx context.Context, d *schema.ResourceData, meta interface{}) diag.Diagnostics {
+	// Only vault_connection, and the credentials and retention of s3, can
+	// change in place, which does not take a snapshot
+	return diag.Diagnostics{}
+}
+
+func resourceRaftSnapshotDelete(ctx context.Context, d *schema.ResourceData, meta interface{}) diag.Diagnostics {
+	// The snapshot is a backup, it outlives the resource
+	return diag.Diagnostics{}
+}
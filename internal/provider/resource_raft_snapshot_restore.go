@@ -0,0 +1,229 @@
+package provider
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"io"
+	"os"
+	"strings"
+	"time"
+
+	"github.com/hashicorp/terraform-plugin-sdk/v2/diag"
+	"github.com/hashicorp/terraform-plugin-sdk/v2/helper/schema"
+	"github.com/hashicorp/vault/api"
+)
+
+const (
+	argForce      = "force"
+	argUnsealKeys = "unseal_keys"
+)
+
+func resourceRaftSnapshotRestore() *schema.Resource {
+	return &schema.Resource{
+		// This description is used by the documentation generator and the language server.
+		Description: "Resource for vault operator raft snapshot restore. It restores a snapshot file once its checksum is verified, then waits for Vault to come back, unsealing it when `unseal_keys` are set. Once unsealed, it waits for the cluster to be healthy with an active node. Destroying the resource does not change the cluster. It requires a token.",
+
+		CreateContext: resourceRaftSnapshotRestoreCreate,
+		ReadContext:   resourceRaftSnapshotRestoreRead,
+		UpdateContext: resourceRaftSnapshotRestoreUpdate,
+		DeleteContext: resourceRaftSnapshotRestoreDelete,
+		Timeouts: &schema.ResourceTimeout{
+			Create: schema.DefaultTimeout(10 * time.Minute),
+		},
+
+		Schema: map[string]*schema.Schema{
+			argVaultConnection: connectionSchema(),
+			argSnapshotPath: {
+				Description: "Local snapshot file to restore.",
+				Type:        schema.TypeString,
+				Required:    true,
+				ForceNew:    true,
+			},
+			argSHA256: {
+				Description: "The expected hex encoded SHA-256 of the snapshot, like the `sha256` of `vaultoperator_raft_snapshot`. The snapshot is not restored when it does not match.",
+				Type:        schema.TypeString,
+				Required:    true,
+				ForceNew:    true,
+			},
+			argForce: {
+				Description: "Restore a snapshot of another cluster. Vault then seals, and has to be unsealed with the keys of that cluster.",
+				Type:        schema.TypeBool,
+				Optional:    true,
+				ForceNew:    true,
+			},
+			argUnsealKeys: {
+				Description: "Unseal keys to unseal Vault with once restored, like the `keys` of `vaultoperator_init`.",
+				Type:        schema.TypeList,
+				Optional:    true,
+				Sensitive:   true,
+				Elem:        &schema.Schema{Type: schema.TypeString},
+			},
+			argSealed: {
+				Description: "Whether Vault was sealed once restored.",
+				Type:        schema.TypeBool,
+				Computed:    true,
+			},
+		},
+	}
+}
+
+func resourceRaftSnapshotRestoreCreate(ctx context.Context, d *schema.ResourceData, meta interface{}) diag.Diagnostics {
+	client, diags := meta.(*apiClient).connection(ctx, d)
+	if diags.HasError() {
+		return diags
+	}
+
+	vaultClient, closeVault, diags := client.vault(ctx)
+	if diags.HasError() {
+		return diags
+	}
+	defer closeVault()
+
+	path := d.Get(argSnapshotPath).(string)
+	if strings.Contains(path, "~") {
+		homeDir, err := homeDir()
+		if err != nil {
+			return diag.FromErr(err)
+		}
+		path = strings.Replace(path, "~", homeDir, -1)
+	}
+
+	f, err := os.Open(path)
+	if err != nil {
+		return diag.FromErr(fmt.Errorf("failed to open the snapshot file: %w", err))
+	}
+	defer f.Close()
+
+	hash := sha256.New()
+	if _, err := io.Copy(hash, f); err != nil {
+		return diag.FromErr(fmt.Errorf("failed to read the snapshot file: %w", err))
+	}
+
+	expected := d.Get(argSHA256).(string)
+	if sum := hex.EncodeToString(hash.Sum(nil)); !strings.EqualFold(sum, expected) {
+		return diag.Errorf("the SHA-256 of %s is %s, expected %s", path, sum, expected)
+	}
+
+	if _, err := f.Seek(0, io.SeekStart); err != nil {
+		return diag.FromErr(fmt.Errorf("failed to read the snapshot file: %w", err))
+	}
+
+	logInfo("restoring the raft snapshot %s", path)
+
+	if err := vaultClient.Sys().RaftSnapshotRestoreWithContext(ctx, f, d.Get(argForce).(bool)); err != nil {
+		logError("failed to restore the raft snapshot: %v", err)
+		return diag.FromErr(err)
+	}
+
+	d.SetId(expected)
+
+	deadline := time.Now().Add(d.Timeout(schema.TimeoutCreate))
+
+	status, err := waitRestored(ctx, vaultClient, deadline)
+	if err != nil {
+		logError("%v", err)
+		return diag.FromErr(err)
+	}
+
+	var keys []string
+	for _, k := range d.Get(argUnsealKeys).([]interface{}) {
+		keys = append(keys, k.(string))
+	}
+
+	if status.Sealed && len(keys) > 0 {
+		logInfo("unsealing Vault after the restore")
+
+		if status, err = unsealPod(ctx, vaultClient, keys, deadline); err != nil {
+			logError("failed to unseal Vault: %v", err)
+			diags = append(diags, diag.Diagnostic{
+				Severity: diag.Warning,
+				Summary:  "Failed to unseal Vault after the restore",
+				Detail:   err.Error(),
+			})
+		}
+	}
+
+	sealed := true
+	if status != nil {
+		sealed = status.Sealed
+	}
+
+	if !sealed {
+		if err := waitHealthy(ctx, vaultClient, deadline); err != nil {
+			logError("%v", err)
+			diags = append(diags, diag.Diagnostic{
+				Severity: diag.Warning,
+				Summary:  "Vault did not become healthy after the restore",
+				Detail:   err.Error(),
+			})
+		}
+	}
+
+	if err := d.Set(argSealed, sealed); err != nil {
+		return append(diags, diag.FromErr(err)...)
+	}
+
+	return diags
+}
+
+// waitRestored polls the seal status of Vault until it answers again after a
+// restore, sealed or not.
+func waitRestored(ctx context.Context, c *api.Client, deadline time.Time) (*api.SealStatusResponse, error) {
+	for {
+		status, err := c.Sys().SealStatusWithContext(ctx)
+		if err == nil && status.Initialized {
+			return status, nil
+		}
+
+		if time.Now().After(deadline) {
+			return nil, fmt.Errorf("timed out waiting for Vault to come back after the restore: %v", err)
+		}
+
+		select {
+		case <-ctx.Done():
+			return nil, ctx.Err()
+		case <-time.After(unsealPollInterval):
+		}
+	}
+}
+
+// waitHealthy waits for the unsealed node to be healthy, active or standby,
+// and for a node of the cluster to be active.
+func waitHealthy(ctx context.Context, c *api.Client, deadline time.Time) error {
+	params := map[string][]string{
+		"standbyok":     {"true"},
+		"perfstandbyok": {"true"},
+	}
+	healthy := func(health *api.HealthResponse, code int) bool {
+		return code >= 200 && code < 300
+	}
+	if _, _, err := waitHealth(ctx, c, params, "healthy", healthy, deadline); err != nil {
+		return err
+	}
+
+	leader, err := waitNewLeader(ctx, c, "", deadline)
+	if err != nil {
+		return err
+	}
+
+	logInfo("Vault is healthy after the restore, %s is the active node", leader.LeaderAddress)
+
+	return nil
+}
+
+func resourceRaftSnapshotRestoreRead(ctx context.Context, d *schema.ResourceData, meta interface{}) diag.Diagnostics {
+	// A snapshot is restored once, there is nothing to refresh
+	return diag.Diagnostics{}
+}
+
+func resourceRaftSnapshotRestoreUpdate(ctx context.Context, d *schema.ResourceData, meta interface{}) diag.Diagnostics {
+	// Only vault_connection and unseal_keys can change in place, which does
+	// not restore the snapshot again
+	return diag.Diagnostics{}
+}
+
+func resourceRaftSnapshotRestoreDelete(ctx context.Context, d *schema.ResourceData, meta interface{}) diag.Diagnostics {
+	return diag.Diagnostics{}
+}
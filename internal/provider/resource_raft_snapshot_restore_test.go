@@ -0,0 +1,204 @@
+package provider
+
+import (
+	"bytes"
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/hashicorp/terraform-plugin-sdk/v2/helper/resource"
+	"github.com/hashicorp/terraform-plugin-sdk/v2/helper/schema"
+	"github.com/hashicorp/vault/api"
+)
+
+var testAccResourceRaftSnapshotRestoreVar = fmt.Sprintf("%[1]s.test", resRaftRestore)
+
+func testAccResourceRaftSnapshotRestore(addr, token, path string) string {
+	return fmt.Sprintf(`
+provider "%[1]s" {
+    vault_addr  = "%[4]s"
+    vault_token = "%[5]s"
+}
+
+resource "%[2]s" "test" {
+	path = "%[6]s"
+}
+
+resource "%[3]s" "test" {
+	path   = %[2]s.test.path
+	sha256 = %[2]s.test.sha256
+}
+`, provider, resRaftSnapshot, resRaftRestore, addr, token, path)
+}
+
+func TestAccResourceRaftSnapshotRestore(t *testing.T) {
+	addr := startRaftVault(t, "node-1")
+	token := initRaftVault(t, addr)
+	path := filepath.Join(t.TempDir(), "vault.snap")
+
+	resource.UnitTest(t, resource.TestCase{
+		PreCheck:          func() { testAccPreCheck(t) },
+		ProviderFactories: providerFactories,
+		Steps: []resource.TestStep{
+			{
+				Config: testAccResourceRaftSnapshotRestore(addr, token, path),
+				Check: resource.ComposeTestCheckFunc(
+					resource.TestCheckResourceAttr(testAccResourceRaftSnapshotRestoreVar, argSealed, "false"),
+				),
+			},
+		},
+	})
+}
+
+// restoreVault is the state of a fake Vault node which restores snapshots.
+// A forced restore seals it until it is unsealed with the three keys k1, k2
+// and k3. Once unsealed, its active node is only known from the second
+// leader read.
+type restoreVault struct {
+	restored    []byte
+	forced      bool
+	leaderReads int
+}
+
+// handleRestore registers the endpoints of the restores on vault.
+func handleRestore(vault *fakeVault) *restoreVault {
+	v := &restoreVault{}
+	sealed := false
+	progress := 0
+
+	restore := func(w http.ResponseWriter, r *http.Request) {
+		var err error
+		if v.restored, err = io.ReadAll(r.Body); err != nil {
+			vault.t.Error(err)
+		}
+		v.forced = r.URL.Path == "/v1/sys/storage/raft/snapshot-force"
+		sealed = v.forced
+		w.WriteHeader(http.StatusNoContent)
+	}
+
+	vault.handle("sys/storage/raft/snapshot", restore)
+	vault.handle("sys/storage/raft/snapshot-force", restore)
+	vault.handle("sys/health", func(w http.ResponseWriter, r *http.Request) {
+		if sealed {
+			w.WriteHeader(http.StatusServiceUnavailable)
+		}
+		json.NewEncoder(w).Encode(api.HealthResponse{Initialized: true, Sealed: sealed})
+	})
+	vault.reply("sys/leader", func() interface{} {
+		v.leaderReads++
+		leader := api.LeaderResponse{HAEnabled: true}
+		if v.leaderReads > 1 {
+			leader.IsSelf = true
+			leader.LeaderAddress = "https://node-1:8200"
+		}
+		return leader
+	})
+	vault.reply("sys/seal-status", func() interface{} {
+		return api.SealStatusResponse{Initialized: true, Sealed: sealed, T: 3, N: 5}
+	})
+	vault.handle("sys/unseal", func(w http.ResponseWriter, r *http.Request) {
+		var body map[string]interface{}
+		vault.decode(r, &body)
+		if key := body["key"].(string); key == fmt.Sprintf("k%d", progress+1) {
+			progress++
+		}
+		sealed = progress < 3
+		json.NewEncoder(w).Encode(api.SealStatusResponse{Initialized: true, Sealed: sealed, T: 3, N: 5, Progress: progress % 3})
+	})
+
+	return v
+}
+
+func TestResourceRaftSnapshotRestore(t *testing.T) {
+	ctx := context.TODO()
+	snapshot := testSnapshot(t)
+	sum := sha256.Sum256(snapshot)
+
+	path := filepath.Join(t.TempDir(), "vault.snap")
+	if err := os.WriteFile(path, snapshot, 0600); err != nil {
+		t.Fatal(err)
+	}
+
+	cases := []struct {
+		name   string
+		sha256 string
+		force  bool
+		keys   []interface{}
+		sealed bool
+		err    bool
+	}{
+		{
+			name:   "restores a snapshot of the cluster",
+			sha256: hex.EncodeToString(sum[:]),
+		},
+		{
+			name:   "unseals with the keys",
+			sha256: hex.EncodeToString(sum[:]),
+			force:  true,
+			keys:   []interface{}{"k1", "k2", "k3", "k4", "k5"},
+		},
+		{
+			name:   "stays sealed without keys",
+			sha256: hex.EncodeToString(sum[:]),
+			force:  true,
+			sealed: true,
+		},
+		{
+			name:   "refuses a mismatching checksum",
+			sha256: hex.EncodeToString(make([]byte, sha256.Size)),
+			force:  true,
+			err:    true,
+		},
+	}
+
+	for _, c := range cases {
+		t.Run(c.name, func(t *testing.T) {
+			fake := newFakeVault(t)
+			vault := handleRestore(fake)
+			meta := fake.meta()
+
+			d := schema.TestResourceDataRaw(t, resourceRaftSnapshotRestore().Schema, map[string]interface{}{
+				argSnapshotPath: path,
+				argSHA256:       c.sha256,
+				argForce:        c.force,
+				argUnsealKeys:   c.keys,
+			})
+
+			diags := resourceRaftSnapshotRestoreCreate(ctx, d, meta)
+			if diags.HasError() != c.err {
+				t.Fatalf("unexpected diagnostics %v", diags)
+			}
+
+			if c.err {
+				if vault.restored != nil {
+					t.Error("the snapshot was restored")
+				}
+				return
+			}
+
+			if len(diags) != 0 {
+				t.Errorf("unexpected diagnostics %v", diags)
+			}
+			if !bytes.Equal(vault.restored, snapshot) {
+				t.Error("the restored snapshot differs from the snapshot file")
+			}
+			if vault.forced != c.force {
+				t.Errorf("expected a forced restore %t", c.force)
+			}
+			if sealed := d.Get(argSealed).(bool); sealed != c.sealed {
+				t.Errorf("expected sealed %t, got %t", c.sealed, sealed)
+			}
+			// A sealed node is not waited for
+			if waited := vault.leaderReads > 1; waited == c.sealed {
+				t.Errorf("expected the active node to be waited for: %t, got %d leader reads", !c.sealed, vault.leaderReads)
+			}
+		})
+	}
+}
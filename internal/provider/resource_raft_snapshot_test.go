@@ -0,0 +1,418 @@
+package provider
+
+import (
+	"archive/tar"
+	"bytes"
+	"compress/gzip"
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"reflect"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"testing"
+
+	"github.com/hashicorp/terraform-plugin-sdk/v2/diag"
+	"github.com/hashicorp/terraform-plugin-sdk/v2/helper/resource"
+	"github.com/hashicorp/terraform-plugin-sdk/v2/helper/schema"
+	"github.com/hashicorp/terraform-plugin-sdk/v2/terraform"
+)
+
+var testAccResourceRaftSnapshotVar = fmt.Sprintf("%[1]s.test", resRaftSnapshot)
+
+func testAccResourceRaftSnapshot(addr, token, path string) string {
+	return fmt.Sprintf(`
+provider "%[1]s" {
+    vault_addr  = "%[3]s"
+    vault_token = "%[4]s"
+}
+
+resource "%[2]s" "test" {
+	path = "%[5]s"
+}
+`, provider, resRaftSnapshot, addr, token, path)
+}
+
+func TestAccResourceRaftSnapshot(t *testing.T) {
+	addr := startRaftVault(t, "node-1")
+	token := initRaftVault(t, addr)
+	path := filepath.Join(t.TempDir(), "vault.snap")
+
+	resource.UnitTest(t, resource.TestCase{
+		PreCheck:          func() { testAccPreCheck(t) },
+		ProviderFactories: providerFactories,
+		Steps: []resource.TestStep{
+			{
+				Config: testAccResourceRaftSnapshot(addr, token, path),
+				Check: resource.ComposeTestCheckFunc(
+					resource.TestCheckResourceAttrSet(testAccResourceRaftSnapshotVar, argSize),
+					resource.TestCheckResourceAttrSet(testAccResourceRaftSnapshotVar, argSHA256),
+					resource.TestCheckResourceAttrSet(testAccResourceRaftSnapshotVar, argTimestamp),
+					func(*terraform.State) error {
+						_, err := os.Stat(path)
+						return err
+					},
+				),
+			},
+		},
+	})
+}
+
+// testSnapshot returns a gzipped tar archive shaped like a raft snapshot,
+// ending with the SHA256SUMS.sealed file the Vault client checks for.
+func testSnapshot(t *testing.T) []byte {
+	t.Helper()
+
+	var buf bytes.Buffer
+	gz := gzip.NewWriter(&buf)
+	tw := tar.NewWriter(gz)
+
+	for _, f := range []struct{ name, content string }{
+		{"meta.json", `{"Index": 42}`},
+		{"state.bin", "state"},
+		{"SHA256SUMS", "sums"},
+		{"SHA256SUMS.sealed", "sealed sums"},
+	} {
+		if err := tw.WriteHeader(&tar.Header{Name: f.name, Mode: 0600, Size: int64(len(f.content))}); err != nil {
+			t.Fatal(err)
+		}
+		if _, err := tw.Write([]byte(f.content)); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	if err := tw.Close(); err != nil {
+		t.Fatal(err)
+	}
+	if err := gz.Close(); err != nil {
+		t.Fatal(err)
+	}
+
+	return buf.Bytes()
+}
+
+func TestResourceRaftSnapshot(t *testing.T) {
+	ctx := context.TODO()
+	snapshot := testSnapshot(t)
+
+	vault := newFakeVault(t)
+	vault.handle("sys/storage/raft/snapshot", func(w http.ResponseWriter, r *http.Request) {
+		w.Write(snapshot)
+	})
+	meta := vault.meta()
+
+	dir := t.TempDir()
+	path := filepath.Join(dir, "vault.snap")
+
+	d := schema.TestResourceDataRaw(t, resourceRaftSnapshot().Schema, map[string]interface{}{
+		argSnapshotPath: path,
+	})
+
+	if diags := resourceRaftSnapshotCreate(ctx, d, meta); diags.HasError() {
+		t.Fatal(diags)
+	}
+
+	written, err := os.ReadFile(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !bytes.Equal(written, snapshot) {
+		t.Error("the snapshot file differs from the snapshot")
+	}
+
+	sum := sha256.Sum256(snapshot)
+	if s := d.Get(argSHA256).(string); s != hex.EncodeToString(sum[:]) {
+		t.Errorf("unexpected sha256 %s", s)
+	}
+	if size := d.Get(argSize).(int); size != len(snapshot) {
+		t.Errorf("expected size %d, got %d", len(snapshot), size)
+	}
+	if d.Get(argTimestamp).(string) == "" {
+		t.Error("expected a timestamp")
+	}
+
+	entries, err := os.ReadDir(dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(entries) != 1 {
+		t.Errorf("expected only the snapshot file, got %d files", len(entries))
+	}
+}
+
+func TestResourceRaftSnapshot_incomplete(t *testing.T) {
+	ctx := context.TODO()
+	snapshot := testSnapshot(t)
+
+	vault := newFakeVault(t)
+	vault.handle("sys/storage/raft/snapshot", func(w http.ResponseWriter, r *http.Request) {
+		w.Write(snapshot[:len(snapshot)/2])
+	})
+	meta := vault.meta()
+
+	dir := t.TempDir()
+	path := filepath.Join(dir, "vault.snap")
+	if err := os.WriteFile(path, []byte("previous"), 0600); err != nil {
+		t.Fatal(err)
+	}
+
+	d := schema.TestResourceDataRaw(t, resourceRaftSnapshot().Schema, map[string]interface{}{
+		argSnapshotPath: path,
+	})
+
+	if diags := resourceRaftSnapshotCreate(ctx, d, meta); !diags.HasError() {
+		t.Fatal("expected an incomplete snapshot to fail")
+	}
+
+	previous, err := os.ReadFile(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if string(previous) != "previous" {
+		t.Error("the previous snapshot was replaced")
+	}
+}
+
+// fakeS3 is an in memory stand-in for MinIO, serving the path style S3
+// requests of snapshot uploads and retention.
+type fakeS3 struct {
+	mu         sync.Mutex
+	objects    map[string][]byte
+	uploads    map[string]map[int][]byte
+	aborted    int
+	encryption string
+	// denyDelete fails the deletion of objects
+	denyDelete bool
+}
+
+func startS3(t *testing.T, objects map[string][]byte) (*httptest.Server, *fakeS3) {
+	t.Helper()
+
+	s3 := &fakeS3{objects: objects, uploads: map[string]map[int][]byte{}}
+
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		s3.mu.Lock()
+		defer s3.mu.Unlock()
+
+		query := r.URL.Query()
+		bucketKey := strings.SplitN(strings.TrimPrefix(r.URL.Path, "/"), "/", 2)
+		if len(bucketKey) == 1 {
+			bucketKey = append(bucketKey, "")
+		}
+		key := bucketKey[1]
+
+		switch {
+		case r.Method == http.MethodGet && query.Get("list-type") == "2":
+			fmt.Fprint(w, "<ListBucketResult><IsTruncated>false</IsTruncated>")
+			for k, v := range s3.objects {
+				if strings.HasPrefix(k, query.Get("prefix")) {
+					fmt.Fprintf(w, "<Contents><Key>%s</Key><Size>%d</Size><LastModified>2022-01-01T00:00:00.000Z</LastModified></Contents>", k, len(v))
+				}
+			}
+			fmt.Fprint(w, "</ListBucketResult>")
+		case r.Method == http.MethodPost && query.Has("uploads"):
+			s3.encryption = r.Header.Get("X-Amz-Server-Side-Encryption")
+			id := fmt.Sprintf("upload-%d", len(s3.uploads))
+			s3.uploads[id] = map[int][]byte{}
+			fmt.Fprintf(w, "<InitiateMultipartUploadResult><Bucket>%s</Bucket><Key>%s</Key><UploadId>%s</UploadId></InitiateMultipartUploadResult>", bucketKey[0], key, id)
+		case r.Method == http.MethodPut && query.Has("uploadId"):
+			part, err := io.ReadAll(r.Body)
+			if err != nil {
+				t.Error(err)
+			}
+			n, _ := strconv.Atoi(query.Get("partNumber"))
+			if r.Header.Get("X-Amz-Content-Sha256") == "STREAMING-AWS4-HMAC-SHA256-PAYLOAD" {
+				part = decodeAWSChunked(t, part)
+			}
+			s3.uploads[query.Get("uploadId")][n] = part
+			w.Header().Set("ETag", fmt.Sprintf(`"etag-%d"`, n))
+		case r.Method == http.MethodPost && query.Has("uploadId"):
+			parts := s3.uploads[query.Get("uploadId")]
+			var object []byte
+			for n := 1; n <= len(parts); n++ {
+				object = append(object, parts[n]...)
+			}
+			s3.objects[key] = object
+			delete(s3.uploads, query.Get("uploadId"))
+			fmt.Fprintf(w, `<CompleteMultipartUploadResult><Bucket>%s</Bucket><Key>%s</Key><ETag>"etag"</ETag></CompleteMultipartUploadResult>`, bucketKey[0], key)
+		case r.Method == http.MethodDelete && query.Has("uploadId"):
+			delete(s3.uploads, query.Get("uploadId"))
+			s3.aborted++
+			w.WriteHeader(http.StatusNoContent)
+		case r.Method == http.MethodDelete && s3.denyDelete:
+			w.WriteHeader(http.StatusForbidden)
+			fmt.Fprintf(w, "<Error><Code>AccessDenied</Code><Message>Access Denied</Message><Key>%s</Key></Error>", key)
+		case r.Method == http.MethodDelete:
+			delete(s3.objects, key)
+			w.WriteHeader(http.StatusNoContent)
+		default:
+			t.Errorf("unexpected S3 request %s %s", r.Method, r.URL)
+			w.WriteHeader(http.StatusNotImplemented)
+		}
+	}))
+	t.Cleanup(server.Close)
+
+	return server, s3
+}
+
+// decodeAWSChunked decodes a body signed in chunks, which minio-go sends over
+// plain HTTP.
+func decodeAWSChunked(t *testing.T, body []byte) []byte {
+	t.Helper()
+
+	var decoded []byte
+	for {
+		header, rest, ok := bytes.Cut(body, []byte("\r\n"))
+		if !ok {
+			t.Errorf("malformed chunk %q", body)
+			return decoded
+		}
+		size, _, _ := strings.Cut(string(header), ";")
+		n, err := strconv.ParseInt(size, 16, 64)
+		if err != nil {
+			t.Error(err)
+			return decoded
+		}
+		if n == 0 {
+			return decoded
+		}
+		decoded = append(decoded, rest[:n]...)
+		body = rest[n+2:]
+	}
+}
+
+func TestResourceRaftSnapshot_s3(t *testing.T) {
+	ctx := context.TODO()
+	snapshot := testSnapshot(t)
+
+	cases := []struct {
+		name     string
+		snapshot []byte
+		objects  map[string][]byte
+		retain   int
+		kept     []string
+		// denyDelete fails the pruning, which only warns
+		denyDelete bool
+		err        bool
+	}{
+		{
+			name:     "uploads the snapshot",
+			snapshot: snapshot,
+			objects:  map[string][]byte{},
+		},
+		{
+			name:     "deletes the oldest snapshots",
+			snapshot: snapshot,
+			objects: map[string][]byte{
+				"vault/vault-raft-20220101T000000Z.snap": nil,
+				"vault/vault-raft-20220102T000000Z.snap": nil,
+				"vault/vault-raft-20220103T000000Z.snap": nil,
+				"vault/other.txt":                        nil,
+			},
+			retain: 2,
+			kept:   []string{"vault/other.txt", "vault/vault-raft-20220103T000000Z.snap"},
+		},
+		{
+			name:     "warns when the pruning fails",
+			snapshot: snapshot,
+			objects: map[string][]byte{
+				"vault/vault-raft-20220101T000000Z.snap": nil,
+			},
+			retain:     1,
+			kept:       []string{"vault/vault-raft-20220101T000000Z.snap"},
+			denyDelete: true,
+		},
+		{
+			name:     "aborts incomplete snapshots",
+			snapshot: snapshot[:len(snapshot)/2],
+			objects:  map[string][]byte{},
+			err:      true,
+		},
+	}
+
+	for _, c := range cases {
+		t.Run(c.name, func(t *testing.T) {
+			vault := newFakeVault(t)
+			vault.handle("sys/storage/raft/snapshot", func(w http.ResponseWriter, r *http.Request) {
+				w.Write(c.snapshot)
+			})
+			meta := vault.meta()
+
+			s3Server, s3 := startS3(t, c.objects)
+			s3.denyDelete = c.denyDelete
+
+			d := schema.TestResourceDataRaw(t, resourceRaftSnapshot().Schema, map[string]interface{}{
+				argS3: []interface{}{
+					map[string]interface{}{
+						argS3Endpoint:          s3Server.URL,
+						argS3Bucket:            "backups",
+						argS3KeyPrefix:         "vault/",
+						argS3Region:            "us-east-1",
+						argS3AccessKey:         "access",
+						argS3SecretKey:         "secret",
+						argS3ServerSideEncrypt: s3EncryptionAES256,
+						argS3Retain:            c.retain,
+					},
+				},
+			})
+
+			diags := resourceRaftSnapshotCreate(ctx, d, meta)
+			if diags.HasError() != c.err {
+				t.Fatalf("unexpected diagnostics %v", diags)
+			}
+
+			if c.err {
+				if len(s3.objects) != 0 {
+					t.Errorf("expected no object, got %d", len(s3.objects))
+				}
+				if s3.aborted != 1 {
+					t.Errorf("expected the upload to be aborted")
+				}
+				return
+			}
+
+			key := d.Get(argS3Key).(string)
+			if !strings.HasPrefix(key, "vault/vault-raft-") {
+				t.Errorf("unexpected key %s", key)
+			}
+			if d.Id() == "" {
+				t.Error("expected the snapshot to be kept in the state")
+			}
+			if warned := len(diags) == 1 && diags[0].Severity == diag.Warning; warned != c.denyDelete {
+				t.Errorf("unexpected diagnostics %v", diags)
+			}
+			if !bytes.Equal(s3.objects[key], snapshot) {
+				t.Error("the uploaded object differs from the snapshot")
+			}
+			if s3.encryption != s3EncryptionAES256 {
+				t.Errorf("expected server-side encryption %s, got %q", s3EncryptionAES256, s3.encryption)
+			}
+
+			sum := sha256.Sum256(snapshot)
+			if s := d.Get(argSHA256).(string); s != hex.EncodeToString(sum[:]) {
+				t.Errorf("unexpected sha256 %s", s)
+			}
+
+			if c.kept != nil {
+				var keys []string
+				for k := range s3.objects {
+					if k != key {
+						keys = append(keys, k)
+					}
+				}
+				sort.Strings(keys)
+				if !reflect.DeepEqual(keys, c.kept) {
+					t.Errorf("expected %v to be kept, got %v", c.kept, keys)
+				}
+			}
+		})
+	}
+}
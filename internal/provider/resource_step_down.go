@@ -0,0 +1,117 @@
+package provider
+
+import (
+	"context"
+	"time"
+
+	"github.com/hashicorp/terraform-plugin-sdk/v2/diag"
+	"github.com/hashicorp/terraform-plugin-sdk/v2/helper/schema"
+)
+
+const (
+	argPreviousLeaderAddress = "previous_leader_address"
+	argLeaderAddress         = "leader_address"
+)
+
+func resourceStepDown() *schema.Resource {
+	return &schema.Resource{
+		// This description is used by the documentation generator and the language server.
+		Description: "Resource for vault operator step-down. When created, or when `triggers` change, the target node steps down if it is the active node, and another node is waited for to become active. Nothing is done when the target node is a standby already. It requires a token.",
+
+		CreateContext: resourceStepDownCreate,
+		ReadContext:   resourceStepDownRead,
+		UpdateContext: resourceStepDownUpdate,
+		DeleteContext: resourceStepDownDelete,
+		Timeouts: &schema.ResourceTimeout{
+			Create: schema.DefaultTimeout(5 * time.Minute),
+		},
+
+		Schema: map[string]*schema.Schema{
+			argVaultConnection: connectionSchema(),
+			argTriggers: {
+				Description: "Arbitrary values which step the node down when they change, like the node about to be replaced.",
+				Type:        schema.TypeMap,
+				Optional:    true,
+				ForceNew:    true,
+				Elem:        &schema.Schema{Type: schema.TypeString},
+			},
+			argPreviousLeaderAddress: {
+				Description: "The address of the active node before the step-down.",
+				Type:        schema.TypeString,
+				Computed:    true,
+			},
+			argLeaderAddress: {
+				Description: "The address of the active node after the step-down.",
+				Type:        schema.TypeString,
+				Computed:    true,
+			},
+		},
+	}
+}
+
+func resourceStepDownCreate(ctx context.Context, d *schema.ResourceData, meta interface{}) diag.Diagnostics {
+	client, diags := meta.(*apiClient).connection(ctx, d)
+	if diags.HasError() {
+		return diags
+	}
+
+	vaultClient, closeVault, diags := client.vault(ctx)
+	if diags.HasError() {
+		return diags
+	}
+	defer closeVault()
+
+	leader, err := vaultClient.Sys().LeaderWithContext(ctx)
+	if err != nil {
+		logError("failed to read the leader: %v", err)
+		return diag.FromErr(err)
+	}
+	if !leader.HAEnabled {
+		return diag.Errorf("Vault does not run in high availability mode, there is no other node to step down to")
+	}
+
+	previous := leader.LeaderAddress
+
+	// A standby would forward the step-down to the active node, which is not
+	// the node targeted
+	if leader.IsSelf {
+		logInfo("stepping down the active node %s", previous)
+
+		if err := vaultClient.Sys().StepDownWithContext(ctx); err != nil {
+			logError("failed to step down: %v", err)
+			return diag.FromErr(err)
+		}
+
+		if leader, err = waitNewLeader(ctx, vaultClient, previous, time.Now().Add(d.Timeout(schema.TimeoutCreate))); err != nil {
+			logError("%v", err)
+			return diag.FromErr(err)
+		}
+	} else {
+		logInfo("the node is a standby of %s already, not stepping down", previous)
+	}
+
+	d.SetId(client.url)
+
+	if err := d.Set(argPreviousLeaderAddress, previous); err != nil {
+		return diag.FromErr(err)
+	}
+	if err := d.Set(argLeaderAddress, leader.LeaderAddress); err != nil {
+		return diag.FromErr(err)
+	}
+
+	return diag.Diagnostics{}
+}
+
+func resourceStepDownRead(ctx context.Context, d *schema.ResourceData, meta interface{}) diag.Diagnostics {
+	// A step-down happens once, there is nothing to refresh
+	return diag.Diagnostics{}
+}
+
+func resourceStepDownUpdate(ctx context.Context, d *schema.ResourceData, meta interface{}) diag.Diagnostics {
+	// Only vault_connection can change in place, which does not step down
+	return diag.Diagnostics{}
+}
+
+func resourceStepDownDelete(ctx context.Context, d *schema.ResourceData, meta interface{}) diag.Diagnostics {
+	return diag.Diagnostics{}
+}
@@ -0,0 +1,98 @@
+package provider
+
+import (
+	"context"
+	"net/http"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/hashicorp/terraform-plugin-sdk/v2/helper/schema"
+	"github.com/hashicorp/vault/api"
+)
+
+// haVault is the state of a fake Vault node of a cluster whose active node
+// changes to next when it steps down. An empty next never elects a new
+// leader.
+type haVault struct {
+	leader    string
+	self      string
+	next      string
+	stepDowns int
+}
+
+// handleHA registers the endpoints of the cluster v on vault.
+func handleHA(vault *fakeVault, v *haVault) {
+	vault.reply("sys/leader", func() interface{} {
+		return api.LeaderResponse{HAEnabled: true, IsSelf: v.leader == v.self, LeaderAddress: v.leader}
+	})
+	vault.handle("sys/step-down", func(w http.ResponseWriter, r *http.Request) {
+		v.stepDowns++
+		v.leader = v.next
+		w.WriteHeader(http.StatusNoContent)
+	})
+}
+
+func TestResourceStepDown(t *testing.T) {
+	ctx := context.TODO()
+
+	for _, tc := range []struct {
+		name      string
+		vault     haVault
+		stepDowns int
+		leader    string
+	}{
+		{
+			name:      "active",
+			vault:     haVault{leader: "https://node-1:8200", self: "https://node-1:8200", next: "https://node-2:8200"},
+			stepDowns: 1,
+			leader:    "https://node-2:8200",
+		},
+		{
+			name:      "standby",
+			vault:     haVault{leader: "https://node-2:8200", self: "https://node-1:8200"},
+			stepDowns: 0,
+			leader:    "https://node-2:8200",
+		},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			vault := tc.vault
+			fake := newFakeVault(t)
+			handleHA(fake, &vault)
+			meta := fake.meta()
+
+			d := schema.TestResourceDataRaw(t, resourceStepDown().Schema, map[string]interface{}{})
+			if diags := resourceStepDownCreate(ctx, d, meta); diags.HasError() {
+				t.Fatal(diags)
+			}
+
+			if vault.stepDowns != tc.stepDowns {
+				t.Errorf("expected %d step-downs, got %d", tc.stepDowns, vault.stepDowns)
+			}
+			if previous := d.Get(argPreviousLeaderAddress).(string); previous != tc.vault.leader {
+				t.Errorf("expected previous leader %s, got %s", tc.vault.leader, previous)
+			}
+			if leader := d.Get(argLeaderAddress).(string); leader != tc.leader {
+				t.Errorf("expected leader %s, got %s", tc.leader, leader)
+			}
+		})
+	}
+}
+
+func TestWaitNewLeaderTimeout(t *testing.T) {
+	ctx := context.TODO()
+	vault := haVault{leader: "https://node-1:8200", self: "https://node-1:8200"}
+	fake := newFakeVault(t)
+	handleHA(fake, &vault)
+
+	vaultClient, closeVault, diags := fake.meta().(*apiClient).vault(ctx)
+	if diags.HasError() {
+		t.Fatal(diags)
+	}
+	defer closeVault()
+
+	_, err := waitNewLeader(ctx, vaultClient, vault.leader, time.Now())
+	if err == nil || !strings.Contains(err.Error(), "timed out") {
+		t.Errorf("expected a timeout, got %v", err)
+	}
+}
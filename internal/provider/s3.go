@@ -0,0 +1,231 @@
+package provider
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"net/url"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/hashicorp/terraform-plugin-sdk/v2/helper/schema"
+	"github.com/hashicorp/terraform-plugin-sdk/v2/helper/validation"
+	"github.com/minio/minio-go/v7"
+	"github.com/minio/minio-go/v7/pkg/credentials"
+	"github.com/minio/minio-go/v7/pkg/encrypt"
+)
+
+const (
+	argS3                  = "s3"
+	argS3Endpoint          = "endpoint"
+	argS3Bucket            = "bucket"
+	argS3KeyPrefix         = "key_prefix"
+	argS3Region            = "region"
+	argS3AccessKey         = "access_key"
+	argS3SecretKey         = "secret_key"
+	argS3SessionToken      = "session_token"
+	argS3ServerSideEncrypt = "server_side_encryption"
+	argS3KMSKeyID          = "kms_key_id"
+	argS3Retain            = "retain"
+	argS3Key               = "s3_key"
+
+	defaultS3Endpoint    = "https://s3.amazonaws.com"
+	s3EncryptionAES256   = "AES256"
+	s3EncryptionKMS      = "aws:kms"
+	s3SnapshotPrefix     = "vault-raft-"
+	s3SnapshotSuffix     = ".snap"
+	s3SnapshotTimeFormat = "20060102T150405Z"
+
+	// s3PartSize bounds the memory used by uploads of unknown size
+	s3PartSize = 16 << 20
+)
+
+// s3Schema is the s3 block of the raft snapshot, which uploads snapshots to
+// an S3-compatible bucket instead of a local file.
+func s3Schema() *schema.Schema {
+	return &schema.Schema{
+		Description: "Upload the snapshot to an S3-compatible bucket, instead of a local file. The snapshot is streamed, without being kept on disk or in memory.",
+		Type:        schema.TypeList,
+		Optional:    true,
+		ForceNew:    true,
+		MaxItems:    1,
+		Elem: &schema.Resource{
+			Schema: map[string]*schema.Schema{
+				argS3Endpoint: {
+					Description: "URL of the S3 API, like `https://minio.example.com:9000`.",
+					Type:        schema.TypeString,
+					Optional:    true,
+					ForceNew:    true,
+					Default:     defaultS3Endpoint,
+				},
+				argS3Bucket: {
+					Description: "Bucket to upload the snapshot to.",
+					Type:        schema.TypeString,
+					Required:    true,
+					ForceNew:    true,
+				},
+				argS3KeyPrefix: {
+					Description: "Prefix of the object key, like `vault/`. The snapshot is named `vault-raft-<timestamp>.snap` after it.",
+					Type:        schema.TypeString,
+					Optional:    true,
+					ForceNew:    true,
+				},
+				argS3Region: {
+					Description: "Region of the bucket. It is looked up when not set.",
+					Type:        schema.TypeString,
+					Optional:    true,
+					ForceNew:    true,
+				},
+				argS3AccessKey: {
+					Description: "Access key. Defaults to `AWS_ACCESS_KEY_ID`.",
+					Type:        schema.TypeString,
+					Optional:    true,
+				},
+				argS3SecretKey: {
+					Description: "Secret key. Defaults to `AWS_SECRET_ACCESS_KEY`.",
+					Type:        schema.TypeString,
+					Optional:    true,
+					Sensitive:   true,
+				},
+				argS3SessionToken: {
+					Description: "Session token of temporary credentials. Defaults to `AWS_SESSION_TOKEN`.",
+					Type:        schema.TypeString,
+					Optional:    true,
+					Sensitive:   true,
+				},
+				argS3ServerSideEncrypt: {
+					Description:  fmt.Sprintf("Server-side encryption of the snapshot, `%s` or `%s`.", s3EncryptionAES256, s3EncryptionKMS),
+					Type:         schema.TypeString,
+					Optional:     true,
+					ForceNew:     true,
+					ValidateFunc: validation.StringInSlice([]string{s3EncryptionAES256, s3EncryptionKMS}, false),
+				},
+				argS3KMSKeyID: {
+					Description: fmt.Sprintf("KMS key to encrypt the snapshot with, when `server_side_encryption` is `%s`. Defaults to the default key of the bucket.", s3EncryptionKMS),
+					Type:        schema.TypeString,
+					Optional:    true,
+					ForceNew:    true,
+				},
+				argS3Retain: {
+					Description:  "Number of snapshots to keep under `key_prefix`, the oldest ones are deleted after an upload. All snapshots are kept when 0.",
+					Type:         schema.TypeInt,
+					Optional:     true,
+					ValidateFunc: validation.IntAtLeast(0),
+				},
+			},
+		},
+	}
+}
+
+// s3Bucket is where snapshots are uploaded to
+type s3Bucket struct {
+	client *minio.Client
+	bucket string
+	prefix string
+	retain int
+	opts   minio.PutObjectOptions
+}
+
+// expandS3 builds the bucket from the s3 block.
+func expandS3(spec map[string]interface{}) (*s3Bucket, error) {
+	endpoint := spec[argS3Endpoint].(string)
+	secure := true
+	if strings.Contains(endpoint, "://") {
+		u, err := url.Parse(endpoint)
+		if err != nil {
+			return nil, fmt.Errorf("failed to parse %q: %w", argS3Endpoint, err)
+		}
+		endpoint = u.Host
+		secure = u.Scheme == "https"
+	}
+
+	creds := credentials.NewEnvAWS()
+	if accessKey := spec[argS3AccessKey].(string); accessKey != "" {
+		creds = credentials.NewStaticV4(accessKey, spec[argS3SecretKey].(string), spec[argS3SessionToken].(string))
+	}
+
+	client, err := minio.New(endpoint, &minio.Options{
+		Creds:  creds,
+		Secure: secure,
+		Region: spec[argS3Region].(string),
+	})
+	if err != nil {
+		return nil, fmt.Errorf("failed to create the S3 client: %w", err)
+	}
+
+	b := &s3Bucket{
+		client: client,
+		bucket: spec[argS3Bucket].(string),
+		prefix: spec[argS3KeyPrefix].(string),
+		retain: spec[argS3Retain].(int),
+		opts: minio.PutObjectOptions{
+			ContentType: "application/gzip",
+			PartSize:    s3PartSize,
+		},
+	}
+
+	switch spec[argS3ServerSideEncrypt].(string) {
+	case s3EncryptionAES256:
+		b.opts.ServerSideEncryption = encrypt.NewSSE()
+	case s3EncryptionKMS:
+		sse, err := encrypt.NewSSEKMS(spec[argS3KMSKeyID].(string), nil)
+		if err != nil {
+			return nil, err
+		}
+		b.opts.ServerSideEncryption = sse
+	}
+
+	return b, nil
+}
+
+// snapshotKey returns the object key of a snapshot taken at timestamp.
+func (b *s3Bucket) snapshotKey(timestamp time.Time) string {
+	return b.prefix + s3SnapshotPrefix + timestamp.UTC().Format(s3SnapshotTimeFormat) + s3SnapshotSuffix
+}
+
+// upload streams r to the object key. The upload is aborted when reading r
+// fails.
+func (b *s3Bucket) upload(ctx context.Context, key string, r io.Reader) error {
+	if _, err := b.client.PutObject(ctx, b.bucket, key, r, -1, b.opts); err != nil {
+		return fmt.Errorf("failed to upload s3://%s/%s: %w", b.bucket, key, err)
+	}
+
+	return nil
+}
+
+// prune deletes the oldest snapshots under the key prefix, keeping the retain
+// most recent ones. Other objects under the prefix are left alone.
+func (b *s3Bucket) prune(ctx context.Context) ([]string, error) {
+	if b.retain == 0 {
+		return nil, nil
+	}
+
+	var keys []string
+	for obj := range b.client.ListObjects(ctx, b.bucket, minio.ListObjectsOptions{Prefix: b.prefix + s3SnapshotPrefix}) {
+		if obj.Err != nil {
+			return nil, fmt.Errorf("failed to list s3://%s/%s: %w", b.bucket, b.prefix, obj.Err)
+		}
+		if strings.HasSuffix(obj.Key, s3SnapshotSuffix) {
+			keys = append(keys, obj.Key)
+		}
+	}
+
+	if len(keys) <= b.retain {
+		return nil, nil
+	}
+
+	// The timestamp in the key sorts the snapshots from the oldest
+	sort.Strings(keys)
+	stale := keys[:len(keys)-b.retain]
+
+	for _, key := range stale {
+		logInfo("deleting snapshot s3://%s/%s", b.bucket, key)
+
+		if err := b.client.RemoveObject(ctx, b.bucket, key, minio.RemoveObjectOptions{}); err != nil {
+			return nil, fmt.Errorf("failed to delete s3://%s/%s: %w", b.bucket, key, err)
+		}
+	}
+
+	return stale, nil
+}
@@ -0,0 +1,271 @@
+package provider
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net"
+	"os"
+	"strings"
+	"sync"
+
+	"golang.org/x/crypto/ssh"
+	"golang.org/x/crypto/ssh/agent"
+	"golang.org/x/crypto/ssh/knownhosts"
+)
+
+const (
+	argSSHTunnel               = "ssh_tunnel"
+	argSSHHost                 = "host"
+	argSSHUser                 = "user"
+	argSSHPrivateKey           = "private_key"
+	argSSHPrivateKeyPassphrase = "private_key_passphrase"
+	argSSHAgent                = "agent"
+	argSSHKnownHosts           = "known_hosts"
+	argSSHHostKey              = "host_key"
+	argSSHJumpHost             = "jump_host"
+
+	defaultSSHPort       = "22"
+	defaultSSHKnownHosts = "~/.ssh/known_hosts"
+	envSSHAuthSock       = "SSH_AUTH_SOCK"
+)
+
+// sshHop is one SSH server on the way to Vault
+type sshHop struct {
+	addr   string
+	config *ssh.ClientConfig
+}
+
+// sshTunnel dials Vault through a chain of SSH servers. The SSH connection
+// is opened on the first dial, and reopened once it is broken.
+type sshTunnel struct {
+	hops []sshHop
+	// agentSocket is the SSH agent to authenticate with, connected to only
+	// while connecting
+	agentSocket string
+
+	mu     sync.Mutex
+	client *ssh.Client
+}
+
+// expandSSHTunnel builds the SSH tunnel from the ssh_tunnel block. The jump
+// hosts are hopped through in order, before reaching the SSH host itself.
+func expandSSHTunnel(spec map[string]interface{}) (*sshTunnel, error) {
+	user := spec[argSSHUser].(string)
+
+	var auth []ssh.AuthMethod
+
+	if key := spec[argSSHPrivateKey].(string); key != "" {
+		var signer ssh.Signer
+		var err error
+		if passphrase := spec[argSSHPrivateKeyPassphrase].(string); passphrase != "" {
+			signer, err = ssh.ParsePrivateKeyWithPassphrase([]byte(key), []byte(passphrase))
+		} else {
+			signer, err = ssh.ParsePrivateKey([]byte(key))
+		}
+		if err != nil {
+			return nil, fmt.Errorf("failed to parse %q: %w", argSSHPrivateKey, err)
+		}
+		auth = append(auth, ssh.PublicKeys(signer))
+	}
+
+	var agentSocket string
+	if spec[argSSHAgent].(bool) {
+		if agentSocket = os.Getenv(envSSHAuthSock); agentSocket == "" {
+			return nil, fmt.Errorf("%q is set, but %s is not", argSSHAgent, envSSHAuthSock)
+		}
+	}
+
+	if len(auth) == 0 && agentSocket == "" {
+		return nil, fmt.Errorf("%q requires %q or %q", argSSHTunnel, argSSHPrivateKey, argSSHAgent)
+	}
+
+	knownHosts := spec[argSSHKnownHosts].(string)
+	if strings.Contains(knownHosts, "~") {
+		homeDir, err := homeDir()
+		if err != nil {
+			return nil, err
+		}
+		knownHosts = strings.Replace(knownHosts, "~", homeDir, -1)
+	}
+
+	hop := func(host, hopUser, hostKey string) (sshHop, error) {
+		if _, _, err := net.SplitHostPort(host); err != nil {
+			host = net.JoinHostPort(host, defaultSSHPort)
+		}
+		if hopUser == "" {
+			hopUser = user
+		}
+
+		var callback ssh.HostKeyCallback
+		if hostKey != "" {
+			key, _, _, _, err := ssh.ParseAuthorizedKey([]byte(hostKey))
+			if err != nil {
+				return sshHop{}, fmt.Errorf("failed to parse the %q of %s: %w", argSSHHostKey, host, err)
+			}
+			callback = ssh.FixedHostKey(key)
+		} else {
+			var err error
+			if callback, err = knownhosts.New(knownHosts); err != nil {
+				return sshHop{}, fmt.Errorf("failed to read %q: %w", argSSHKnownHosts, err)
+			}
+		}
+
+		return sshHop{
+			addr: host,
+			config: &ssh.ClientConfig{
+				User:            hopUser,
+				Auth:            auth,
+				HostKeyCallback: callback,
+			},
+		}, nil
+	}
+
+	t := &sshTunnel{agentSocket: agentSocket}
+
+	for _, j := range spec[argSSHJumpHost].([]interface{}) {
+		jump := j.(map[string]interface{})
+		h, err := hop(jump[argSSHHost].(string), jump[argSSHUser].(string), jump[argSSHHostKey].(string))
+		if err != nil {
+			return nil, err
+		}
+		t.hops = append(t.hops, h)
+	}
+
+	h, err := hop(spec[argSSHHost].(string), user, spec[argSSHHostKey].(string))
+	if err != nil {
+		return nil, err
+	}
+	t.hops = append(t.hops, h)
+
+	return t, nil
+}
+
+// connect opens the SSH connection, hopping through every SSH server in
+// turn.
+func (t *sshTunnel) connect(ctx context.Context) (*ssh.Client, error) {
+	var agentAuth []ssh.AuthMethod
+	if t.agentSocket != "" {
+		conn, err := net.Dial("unix", t.agentSocket)
+		if err != nil {
+			return nil, fmt.Errorf("failed to connect to the SSH agent: %w", err)
+		}
+		// The agent is only needed for the handshakes
+		defer conn.Close()
+		agentAuth = []ssh.AuthMethod{ssh.PublicKeysCallback(agent.NewClient(conn).Signers)}
+	}
+
+	var client *ssh.Client
+
+	for _, hop := range t.hops {
+		var conn net.Conn
+		var err error
+		if client == nil {
+			var d net.Dialer
+			conn, err = d.DialContext(ctx, "tcp", hop.addr)
+		} else {
+			conn, err = dialSSH(ctx, client, "tcp", hop.addr)
+		}
+		if err != nil {
+			if client != nil {
+				client.Close()
+			}
+			return nil, fmt.Errorf("failed to connect to SSH server %s: %w", hop.addr, err)
+		}
+
+		config := *hop.config
+		config.Auth = append(config.Auth[:len(config.Auth):len(config.Auth)], agentAuth...)
+
+		c, chans, reqs, err := ssh.NewClientConn(conn, hop.addr, &config)
+		if err != nil {
+			conn.Close()
+			if client != nil {
+				client.Close()
+			}
+			return nil, fmt.Errorf("failed to connect to SSH server %s: %w", hop.addr, err)
+		}
+
+		logDebug("connected to SSH server %s", hop.addr)
+		client = ssh.NewClient(c, chans, reqs)
+	}
+
+	return client, nil
+}
+
+// dialContext dials addr from the last SSH server of the tunnel. It is used
+// as the DialContext of the Vault client transport.
+func (t *sshTunnel) dialContext(ctx context.Context, network, addr string) (net.Conn, error) {
+	client, err := t.sshClient(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	conn, err := dialSSH(ctx, client, network, addr)
+	if err != nil {
+		// A rejected channel, like a refused connection to addr, leaves the
+		// SSH connection working for the other dials
+		var openErr *ssh.OpenChannelError
+		if !errors.As(err, &openErr) && ctx.Err() == nil {
+			t.reset(client)
+		}
+		return nil, fmt.Errorf("failed to dial %s through the SSH tunnel: %w", addr, err)
+	}
+
+	return conn, nil
+}
+
+// sshClient returns the SSH connection, opening it if needed.
+func (t *sshTunnel) sshClient(ctx context.Context) (*ssh.Client, error) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	if t.client == nil {
+		client, err := t.connect(ctx)
+		if err != nil {
+			return nil, err
+		}
+		t.client = client
+	}
+
+	return t.client, nil
+}
+
+// reset closes the broken SSH connection client, for the next dial to
+// reconnect, unless it was reconnected already.
+func (t *sshTunnel) reset(client *ssh.Client) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	client.Close()
+	if t.client == client {
+		t.client = nil
+	}
+}
+
+// dialSSH dials addr from the SSH server of client, giving up when ctx is
+// done.
+func dialSSH(ctx context.Context, client *ssh.Client, network, addr string) (net.Conn, error) {
+	type dialed struct {
+		conn net.Conn
+		err  error
+	}
+
+	result := make(chan dialed, 1)
+	go func() {
+		conn, err := client.Dial(network, addr)
+		result <- dialed{conn, err}
+	}()
+
+	select {
+	case r := <-result:
+		return r.conn, r.err
+	case <-ctx.Done():
+		// Close the connection the dial may still open
+		go func() {
+			if r := <-result; r.conn != nil {
+				r.conn.Close()
+			}
+		}()
+		return nil, ctx.Err()
+	}
+}
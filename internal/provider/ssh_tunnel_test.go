@@ -0,0 +1,219 @@
+package provider
+
+import (
+	"context"
+	"crypto/x509"
+	"encoding/pem"
+	"fmt"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"testing"
+
+	"github.com/hashicorp/terraform-plugin-sdk/v2/terraform"
+	"golang.org/x/crypto/ssh"
+	"golang.org/x/crypto/ssh/agent"
+	"golang.org/x/crypto/ssh/knownhosts"
+)
+
+func TestProvider_configure_ssh_tunnel(t *testing.T) {
+	vault := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/json")
+		w.Write([]byte(`{"initialized": true}`))
+	}))
+	defer vault.Close()
+	vaultHost := strings.TrimPrefix(vault.URL, "http://")
+
+	key := testKey(t)
+	privateKey := string(pem.EncodeToMemory(&pem.Block{Type: "RSA PRIVATE KEY", Bytes: x509.MarshalPKCS1PrivateKey(key)}))
+	signer, err := ssh.NewSignerFromKey(key)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	bastion, bastionKey, bastionForwards := startSSHServer(t, signer.PublicKey())
+	jump, jumpKey, jumpForwards := startSSHServer(t, signer.PublicKey())
+
+	authorizedKey := func(key ssh.PublicKey) string {
+		return string(ssh.MarshalAuthorizedKey(key))
+	}
+
+	knownHosts := filepath.Join(t.TempDir(), "known_hosts")
+	if err := os.WriteFile(knownHosts, []byte(knownhosts.Line([]string{bastion}, bastionKey)+"\n"), 0600); err != nil {
+		t.Fatal(err)
+	}
+
+	// The agent holds the key, instead of the configuration
+	socket := filepath.Join(t.TempDir(), "agent.sock")
+	listener, err := net.Listen("unix", socket)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer listener.Close()
+	keyring := agent.NewKeyring()
+	if err := keyring.Add(agent.AddedKey{PrivateKey: key}); err != nil {
+		t.Fatal(err)
+	}
+	var agentConns sync.WaitGroup
+	go func() {
+		for {
+			conn, err := listener.Accept()
+			if err != nil {
+				return
+			}
+			agentConns.Add(1)
+			go func() {
+				defer agentConns.Done()
+				agent.ServeAgent(keyring, conn)
+			}()
+		}
+	}()
+	t.Setenv(envSSHAuthSock, socket)
+
+	cases := []struct {
+		name     string
+		tunnel   map[string]interface{}
+		forwards []string
+	}{
+		{
+			name: "host key",
+			tunnel: map[string]interface{}{
+				argSSHHost:       bastion,
+				argSSHUser:       "vault",
+				argSSHPrivateKey: privateKey,
+				argSSHHostKey:    authorizedKey(bastionKey),
+			},
+			forwards: []string{vaultHost},
+		},
+		{
+			name: "known hosts",
+			tunnel: map[string]interface{}{
+				argSSHHost:       bastion,
+				argSSHUser:       "vault",
+				argSSHPrivateKey: privateKey,
+				argSSHKnownHosts: knownHosts,
+			},
+			forwards: []string{vaultHost},
+		},
+		{
+			name: "agent",
+			tunnel: map[string]interface{}{
+				argSSHHost:    bastion,
+				argSSHUser:    "vault",
+				argSSHAgent:   true,
+				argSSHHostKey: authorizedKey(bastionKey),
+			},
+			forwards: []string{vaultHost},
+		},
+		{
+			name: "jump host",
+			tunnel: map[string]interface{}{
+				argSSHHost:       bastion,
+				argSSHUser:       "vault",
+				argSSHPrivateKey: privateKey,
+				argSSHHostKey:    authorizedKey(bastionKey),
+				argSSHJumpHost: []interface{}{map[string]interface{}{
+					argSSHHost:    jump,
+					argSSHHostKey: authorizedKey(jumpKey),
+				}},
+			},
+			forwards: []string{bastion, vaultHost},
+		},
+	}
+
+	for _, c := range cases {
+		t.Run(c.name, func(t *testing.T) {
+			*bastionForwards = nil
+			*jumpForwards = nil
+
+			rc := terraform.NewResourceConfigRaw(map[string]interface{}{
+				argVaultAddr: vault.URL,
+				argSSHTunnel: []interface{}{c.tunnel},
+			})
+			p := New("dev")()
+			diags := configureNow(context.TODO(), p, rc)
+			if diags.HasError() {
+				t.Fatal(diags)
+			}
+
+			initialized, err := p.Meta().(*apiClient).client.Sys().InitStatus()
+			if err != nil {
+				t.Fatal(err)
+			}
+			if !initialized {
+				t.Fatal("expected the init status from Vault")
+			}
+
+			forwards := append(*jumpForwards, *bastionForwards...)
+			if strings.Join(forwards, ",") != strings.Join(c.forwards, ",") {
+				t.Fatalf("expected forwards %v, got %v", c.forwards, forwards)
+			}
+		})
+	}
+
+	// The agent connections are closed once connected
+	agentConns.Wait()
+
+	t.Run("refused dial", func(t *testing.T) {
+		rc := terraform.NewResourceConfigRaw(map[string]interface{}{
+			argVaultAddr: vault.URL,
+			argSSHTunnel: []interface{}{map[string]interface{}{
+				argSSHHost:       bastion,
+				argSSHUser:       "vault",
+				argSSHPrivateKey: privateKey,
+				argSSHHostKey:    authorizedKey(bastionKey),
+			}},
+		})
+		p := New("dev")()
+		diags := configureNow(context.TODO(), p, rc)
+		if diags.HasError() {
+			t.Fatal(diags)
+		}
+
+		tunnel := p.Meta().(*apiClient).tunnel
+		conn, err := tunnel.dialContext(context.TODO(), "tcp", vaultHost)
+		if err != nil {
+			t.Fatal(err)
+		}
+		defer conn.Close()
+		client := tunnel.client
+
+		if _, err := tunnel.dialContext(context.TODO(), "tcp", fmt.Sprintf("127.0.0.1:%d", freePort(t))); err == nil {
+			t.Fatal("expected the dial to be refused")
+		}
+		if tunnel.client != client {
+			t.Error("expected the SSH connection to be kept after a refused dial")
+		}
+
+		if _, err := p.Meta().(*apiClient).client.Sys().InitStatus(); err != nil {
+			t.Fatal(err)
+		}
+	})
+
+	t.Run("host key mismatch", func(t *testing.T) {
+		rc := terraform.NewResourceConfigRaw(map[string]interface{}{
+			argVaultAddr: vault.URL,
+			argSSHTunnel: []interface{}{map[string]interface{}{
+				argSSHHost:       bastion,
+				argSSHUser:       "vault",
+				argSSHPrivateKey: privateKey,
+				argSSHHostKey:    authorizedKey(jumpKey),
+			}},
+		})
+		p := New("dev")()
+		diags := configureNow(context.TODO(), p, rc)
+		if diags.HasError() {
+			t.Fatal(diags)
+		}
+
+		client := p.Meta().(*apiClient).client
+		client.SetMaxRetries(0)
+		if _, err := client.Sys().InitStatus(); err == nil || !strings.Contains(err.Error(), "host key mismatch") {
+			t.Fatalf("expected a host key mismatch, got %v", err)
+		}
+	})
+}
@@ -0,0 +1,14 @@
+disable_mlock = true
+api_addr = "http://127.0.0.1:{{ .APIPort }}"
+cluster_addr = "http://127.0.0.1:{{ .ClusterPort }}"
+
+listener "tcp" {
+    address = "127.0.0.1:{{ .APIPort }}"
+    cluster_address = "127.0.0.1:{{ .ClusterPort }}"
+    tls_disable = "1"
+}
+
+storage "raft" {
+    path = "{{ .DataDir }}"
+    node_id = "{{ .NodeID }}"
+}